@@ -2,21 +2,23 @@ package ccw
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"embed"
-	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 	"unicode"
 	"unicode/utf8"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/time/rate"
 )
 
@@ -47,27 +49,101 @@ type Client struct {
 	// QuoteService represents the CCW Quote Service
 	QuoteService *QuoteService
 
-	username     string
-	password     string
-	clientID     string
-	clientSecret string
+	// RetryPolicy controls how failed requests are retried, defaulting to DefaultRetryPolicy.
+	RetryPolicy RetryPolicy
 
-	token *ccwToken
-	mu    sync.Mutex
+	tokenSource TokenSource
+	token       *ccwToken
+	mu          sync.Mutex
 
-	lim *rate.Limiter
+	// limMu guards the client's and services' limiters, which may be changed while requests are made.
+	limMu     sync.RWMutex
+	lim       *rate.Limiter
+	logger    Logger
+	userAgent string
+	telemetry *telemetry
 }
 
-// EstimateService represents the CCW Quote Service
+// EstimateService represents the CCW Estimate Service
 type EstimateService struct {
 	BaseURL string
 	client  *Client
+	lim     *rate.Limiter
 }
 
 // QuoteService represents the CCW Quote Service
 type QuoteService struct {
 	BaseURL string
 	client  *Client
+	lim     *rate.Limiter
+}
+
+// DefaultRateLimit and DefaultRateBurst are the rate limit used by new clients, shared by all services.
+const (
+	DefaultRateLimit = rate.Limit(100)
+	DefaultRateBurst = 1
+)
+
+// SetRateLimit sets the maximum number of requests per second, and the burst size, across all
+// services that don't have their own rate limit.
+func (c *Client) SetRateLimit(r rate.Limit, burst int) {
+	c.SetLimiter(rate.NewLimiter(r, burst))
+}
+
+// SetLimiter sets the limiter used by all services that don't have their own rate limit.  Use this
+// to share one limiter between several clients that use the same API credentials.  It is safe to
+// change the limiter while requests are being made; requests already waiting keep the old limiter.
+func (c *Client) SetLimiter(lim *rate.Limiter) {
+	c.limMu.Lock()
+	defer c.limMu.Unlock()
+	c.lim = lim
+}
+
+// limiter returns the limiter used by services that don't have their own rate limit.
+func (c *Client) limiter() *rate.Limiter {
+	c.limMu.RLock()
+	defer c.limMu.RUnlock()
+	return c.lim
+}
+
+// SetRateLimit gives the estimate service its own rate limit, rather than using the client's.
+func (s *EstimateService) SetRateLimit(r rate.Limit, burst int) {
+	s.SetLimiter(rate.NewLimiter(r, burst))
+}
+
+// SetLimiter gives the estimate service its own limiter, rather than using the client's.  The
+// limiter may be shared with other clients.
+func (s *EstimateService) SetLimiter(lim *rate.Limiter) {
+	s.client.limMu.Lock()
+	defer s.client.limMu.Unlock()
+	s.lim = lim
+}
+
+// limiter returns the estimate service's own limiter, or nil if it uses the client's.
+func (s *EstimateService) limiter() *rate.Limiter {
+	s.client.limMu.RLock()
+	defer s.client.limMu.RUnlock()
+	return s.lim
+}
+
+// SetRateLimit gives the quote service its own rate limit, rather than using the client's.
+func (s *QuoteService) SetRateLimit(r rate.Limit, burst int) {
+	s.SetLimiter(rate.NewLimiter(r, burst))
+}
+
+// SetLimiter gives the quote service its own limiter, rather than using the client's.  The
+// limiter may be shared with other clients.
+func (s *QuoteService) SetLimiter(lim *rate.Limiter) {
+	s.client.limMu.Lock()
+	defer s.client.limMu.Unlock()
+	s.lim = lim
+}
+
+// limiter returns the quote service's own limiter, or nil if it uses the client's.
+func (s *QuoteService) limiter() *rate.Limiter {
+	s.client.limMu.RLock()
+	defer s.client.limMu.RUnlock()
+	return s.lim
 }
 
 // NewClient is a helper function that returns an new ccw client given the required parameters.
@@ -75,43 +151,120 @@ type QuoteService struct {
 // ensure you're aware of the decision you're making to not provide your own http client.
 // Each service maintains it's own BaseURL which you can change after calling NewClient if you wish
 // to use a different URL.
+//
+// NewClient is kept for compatibility, New provides more options.
 func NewClient(username, password, clientID, secret string, client *http.Client) (*Client, error) {
-	if username == "" || password == "" || clientID == "" || secret == "" {
-		return nil, errors.New("missing required parameters")
+	return New(WithCredentials(username, password, clientID, secret), WithHTTPClient(client))
+}
+
+// NewClientWithTokenSource returns a new ccw client that uses the given TokenSource to
+// authenticate requests.  As with NewClient, you can provide your own http client or use nil
+// to use the default.
+func NewClientWithTokenSource(ts TokenSource, client *http.Client) (*Client, error) {
+	return New(WithTokenSource(ts), WithHTTPClient(client))
+}
+
+// makeXMLRequest posts the body to the given url and decodes the XML response into v, returning
+// the raw response body.  Requests wait on the given rate limiter, or the client's limiter if it is
+// nil.  The request body is held as a byte slice so that a fresh request can be
+// built for each attempt, allowing failed requests to be retried according to the client's
+// RetryPolicy.
+func (c *Client) makeXMLRequest(ctx context.Context, lim *rate.Limiter, url string, body []byte, v interface{}) ([]byte, error) {
+	if lim == nil {
+		lim = c.limiter()
 	}
-	if client == nil {
-		client = &http.Client{
-			Timeout: 10 * time.Second,
+	reauthenticated := false
+	for attempt := 1; ; attempt++ {
+		if err := c.waitForLimiter(ctx, lim); err != nil {
+			return nil, err
 		}
+		token, err := c.getToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting token: %w", err)
+		}
+		raw, err := c.doXMLRequest(ctx, token, url, body, v)
+		if err == nil {
+			return raw, nil
+		}
+		if errors.Is(err, ErrUnauthorized) && !reauthenticated {
+			// the token may have been revoked, so discard it and try once more with a new one
+			reauthenticated = true
+			c.invalidateToken(token)
+			c.telemetry.retries.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("ccw.operation", operationFromContext(ctx)),
+				attribute.String("ccw.reason", "unauthorized"),
+			))
+			continue
+		}
+		retry, retryAfter := retryable(err, isIdempotent(ctx))
+		if !retry || attempt >= c.RetryPolicy.MaxAttempts || ctx.Err() != nil {
+			return raw, err
+		}
+		wait := c.RetryPolicy.backoff(attempt, retryAfter)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			// the context would expire before the next attempt could be made
+			return raw, err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return raw, err
+		case <-timer.C:
+		}
+		c.telemetry.retries.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("ccw.operation", operationFromContext(ctx)),
+			attribute.String("ccw.reason", "transient"),
+		))
 	}
-	rl := rate.NewLimiter(100, 1)
-	c := &Client{
-		HTTPClient:   client,
-		username:     username,
-		password:     password,
-		clientID:     clientID,
-		clientSecret: secret,
-		lim:          rl,
-	}
-
-	c.EstimateService = &EstimateService{client: c, BaseURL: "https://api.cisco.com/commerce/EST/v2/async"}
-	c.QuoteService = &QuoteService{client: c, BaseURL: "https://api.cisco.com/commerce/QUOTING/v1"}
-
-	return c, nil
 }
 
-func (c *Client) makeXMLRequest(ctx context.Context, req *http.Request, v interface{}) error {
-	err := c.checkLimitAndGetToken(ctx)
+// doXMLRequest makes a single attempt at a request, returning the raw response body.  Responses
+// with an unsuccessful status code are returned as an *APIError.
+func (c *Client) doXMLRequest(ctx context.Context, token *ccwToken, url string, body []byte, v interface{}) (raw []byte, err error) {
+	ctx, span := c.telemetry.tracer.Start(ctx, "ccw.request",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", "POST"),
+			attribute.String("http.url", url),
+		),
+	)
+	start := time.Now()
+	status := 0
+	defer func() {
+		c.telemetry.recordRequest(ctx, status, time.Since(start), err != nil)
+		if status != 0 {
+			span.SetAttributes(attribute.Int("http.status_code", status))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("error getting token: %w", err)
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/xml")
+	req.Header.Add("Content-Type", "application/xml")
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token.AccessToken))
-	rc := req.WithContext(ctx)
-	res, err := c.HTTPClient.Do(rc)
+	c.logger.Debug("ccw request started", "url", url)
+	res, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return err
+		c.logger.Debug("ccw request failed", "url", url, "duration", time.Since(start), "error", err)
+		return nil, err
 	}
 	defer res.Body.Close()
+	status = res.StatusCode
+	raw, err = io.ReadAll(NewValidUTF8Reader(res.Body))
+	c.logger.Debug("ccw request finished", "url", url, "status", res.StatusCode, "duration", time.Since(start))
+	if err != nil {
+		return nil, err
+	}
 	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
 		var ccwErr error
 		switch res.StatusCode {
@@ -121,84 +274,109 @@ func (c *Client) makeXMLRequest(ctx context.Context, req *http.Request, v interf
 			ccwErr = ErrUnauthorized
 		case 403:
 			ccwErr = ErrForbidden
+		case 429:
+			ccwErr = ErrTooManyRequests
 		case 500:
 			ccwErr = ErrInternalError
+		case 503:
+			ccwErr = ErrServiceUnavailable
 		default:
 			ccwErr = ErrUnknown
 		}
-		return ccwErr
+		fault, ebmsErrors := parseFault(raw)
+		if fault != nil && fault.isClient() && res.StatusCode >= http.StatusInternalServerError {
+			// SOAP 1.1 returns every fault with a 500, even those caused by the request
+			ccwErr = ErrBadRequest
+		}
+		return raw, &APIError{
+			StatusCode: res.StatusCode,
+			Fault:      fault,
+			EBMSErrors: ebmsErrors,
+			Body:       raw,
+			Err:        ccwErr,
+			retryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+		}
 	}
 	if res.StatusCode == http.StatusCreated {
-		return nil
+		return raw, nil
 	}
-	body := NewValidUTF8Reader(res.Body)
-	if err = xml.NewDecoder(body).Decode(&v); err != nil {
-		return err
+	// faults are occasionally returned with a successful status code
+	if fault, ebmsErrors := parseFault(raw); fault != nil || hasEBMSFailure(ebmsErrors) {
+		apiErr := &APIError{
+			StatusCode: res.StatusCode,
+			Fault:      fault,
+			EBMSErrors: ebmsErrors,
+			Body:       raw,
+			Err:        ErrInternalError,
+		}
+		if fault == nil || fault.isClient() {
+			apiErr.Err = ErrBadRequest
+		}
+		return raw, apiErr
 	}
-	return nil
+	if err = xml.Unmarshal(raw, v); err != nil {
+		return raw, err
+	}
+	return raw, nil
 }
 
-func (c *Client) checkLimitAndGetToken(ctx context.Context) error {
-	if !c.lim.Allow() {
-		c.lim.Wait(ctx)
-	}
-	err := c.getToken()
+// waitForLimiter waits until the limiter allows a request, recording how long it waited.
+func (c *Client) waitForLimiter(ctx context.Context, lim *rate.Limiter) error {
+	start := time.Now()
+	err := lim.Wait(ctx)
+	c.telemetry.limiterWait.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("ccw.operation", operationFromContext(ctx)),
+	))
 	if err != nil {
-		return err
+		return fmt.Errorf("waiting for rate limiter: %w", err)
 	}
 	return nil
 }
 
-// getToken is a helper function to reuse an existing or retrieve a new token
-func (c *Client) getToken() error {
+// invalidateToken discards the given token, if it is still the current token, so that a new
+// token is retrieved for the next request.  If the token source caches tokens itself, it is asked
+// to discard its token too.
+func (c *Client) invalidateToken(token *ccwToken) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.token != nil && c.token.ExpiresAt.After(time.Now().Add(time.Duration(time.Minute*5))) {
-		return nil
+	if c.token != token {
+		return
 	}
-	t, err := c.generateToken()
-	if err != nil {
-		log.Println("error retrieving token")
-		return err
+	c.token = nil
+	c.logger.Debug("ccw token invalidated")
+	if inv, ok := c.tokenSource.(tokenInvalidator); ok {
+		inv.Invalidate()
 	}
-	c.token = t
-	return nil
 }
 
-func (c *Client) generateToken() (*ccwToken, error) {
-	u := "https://cloudsso.cisco.com/as/token.oauth2"
-	method := "POST"
-	username := c.username
-	password := c.password
-	clientID := c.clientID
-	clientSecret := c.clientSecret
-	payload := fmt.Sprintf("grant_type=password&username=%s&password=%s&client_id=%s&client_secret=%s", username, password, clientID, clientSecret)
-	req, err := http.NewRequest(method, u, strings.NewReader(payload))
-	if err != nil {
-		return nil, err
+// getToken is a helper function to reuse an existing or retrieve a new token
+func (c *Client) getToken(ctx context.Context) (*ccwToken, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != nil && (c.token.ExpiresAt.IsZero() || c.token.ExpiresAt.After(time.Now().Add(tokenExpiryMargin))) {
+		return c.token, nil
 	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	res, err := c.HTTPClient.Do(req)
+	ctx, span := c.telemetry.tracer.Start(ctx, "ccw.token")
+	defer span.End()
+	t, err := c.tokenSource.Token()
 	if err != nil {
+		c.telemetry.tokenRefreshes.Add(ctx, 1, metric.WithAttributes(attribute.Bool("ccw.success", false)))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.logger.Debug("ccw token refresh failed", "error", err)
 		return nil, err
 	}
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		var e tokenError
-		err = json.NewDecoder(res.Body).Decode(&e)
-		if err != nil {
-			return nil, err
-		}
-		return nil, errors.New(e.Description)
+	c.telemetry.tokenRefreshes.Add(ctx, 1, metric.WithAttributes(attribute.Bool("ccw.success", true)))
+	c.logger.Debug("ccw token refreshed", "expires_at", t.Expiry)
+	c.token = &ccwToken{
+		AccessToken: t.AccessToken,
+		TokenType:   t.TokenType,
+		ExpiresAt:   t.Expiry,
 	}
-	var t ccwToken
-	err = json.NewDecoder(res.Body).Decode(&t)
-	if err != nil {
-		return nil, err
+	if !t.Expiry.IsZero() {
+		c.token.ExpiresIn = int(time.Until(t.Expiry).Seconds())
 	}
-	t.ExpiresAt = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
-	c.token = &t
-	return &t, nil
+	return c.token, nil
 }
 
 // String is a helper routine that allocates a new string value
@@ -215,6 +393,10 @@ func String(v string) *string {
 // to store v and returns a pointer to it.
 func Int64(v int64) *int64 { return &v }
 
+// Float64 is a helper routine that allocates a new float64 value
+// to store v and returns a pointer to it.
+func Float64(v float64) *float64 { return &v }
+
 // IntOrNil is a helper routine that allocates a new int64 value
 // to store v and returns a pointer to it, unless it's zero in
 // which case it will return nil. Use this for values you don't
@@ -240,11 +422,17 @@ func FloatOrNil(v float64) *float64 {
 // ValidUTF8Reader implements a Reader which reads only bytes that constitute valid UTF-8
 type ValidUTF8Reader struct {
 	buffer *bufio.Reader
+	// pending holds the rest of a rune that didn't fit in the caller's buffer
+	pending *[]byte
 }
 
-// Function Read reads bytes in the byte array b. n is the number of bytes read.
+// Function Read reads bytes in the byte array b. n is the number of bytes read.  Read fills b
+// completely unless the input is exhausted, splitting a rune across calls if necessary, so it
+// only returns no bytes with a nil error if b is empty.
 func (rd ValidUTF8Reader) Read(b []byte) (n int, err error) {
-	for {
+	n = copy(b, *rd.pending)
+	*rd.pending = (*rd.pending)[n:]
+	for n < len(b) {
 		var r rune
 		var size int
 		r, size, err = rd.buffer.ReadRune()
@@ -253,18 +441,21 @@ func (rd ValidUTF8Reader) Read(b []byte) (n int, err error) {
 		}
 		if r == unicode.ReplacementChar && size == 1 {
 			continue
-		} else if n+size < len(b) {
-			utf8.EncodeRune(b[n:], r)
-			n += size
-		} else {
-			rd.buffer.UnreadRune()
-			break
 		}
+		if n+size > len(b) {
+			var rb [utf8.UTFMax]byte
+			utf8.EncodeRune(rb[:], r)
+			copied := copy(b[n:], rb[:size])
+			*rd.pending = append((*rd.pending)[:0], rb[copied:size]...)
+			return len(b), nil
+		}
+		utf8.EncodeRune(b[n:], r)
+		n += size
 	}
 	return
 }
 
 // NewValidUTF8Reader constructs a new ValidUTF8Reader that wraps an existing io.Reader
 func NewValidUTF8Reader(rd io.Reader) ValidUTF8Reader {
-	return ValidUTF8Reader{bufio.NewReader(rd)}
+	return ValidUTF8Reader{buffer: bufio.NewReader(rd), pending: new([]byte)}
 }
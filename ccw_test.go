@@ -0,0 +1,147 @@
+package ccw
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func Test_RateLimiter(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`<Envelope/>`))
+	}))
+	defer srv.Close()
+
+	shared := rate.NewLimiter(rate.Every(time.Hour), 1)
+	c1, _ := NewClientWithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"}), nil)
+	c2, _ := NewClientWithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"}), nil)
+	c1.SetLimiter(shared)
+	c2.QuoteService.SetLimiter(shared)
+
+	if _, err := c1.makeXMLRequest(context.Background(), c1.QuoteService.lim, srv.URL, nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the shared limiter has no tokens left, so waiting should fail rather than continue
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c2.makeXMLRequest(ctx, c2.QuoteService.lim, srv.URL, nil, &struct{}{})
+	if err == nil {
+		t.Fatal("expected an error waiting for the shared limiter")
+	}
+
+	// the estimate service on the second client still uses its own client's limiter
+	if _, err := c2.makeXMLRequest(context.Background(), c2.EstimateService.lim, srv.URL, nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	c2.SetRateLimit(rate.Every(time.Hour), 1)
+	_, err = c2.makeXMLRequest(cancelled, nil, srv.URL, nil, &struct{}{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled context to return an error, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "waiting for rate limiter") {
+		t.Errorf("expected a rate limiter error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 requests to be made, got %d", calls)
+	}
+}
+
+func Test_SetLimiterWhileRequesting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<Envelope/>`))
+	}))
+	defer srv.Close()
+	c, _ := NewClientWithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"}), nil)
+
+	// run with -race to check limiters can be changed while requests are being made
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := c.makeXMLRequest(context.Background(), c.QuoteService.limiter(), srv.URL, nil, &struct{}{}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		c.SetRateLimit(rate.Inf, 1)
+		c.QuoteService.SetRateLimit(rate.Inf, 1)
+		c.EstimateService.SetLimiter(nil)
+	}
+	wg.Wait()
+}
+
+func Test_ValidUTF8Reader(t *testing.T) {
+	// more than the 512 bytes io.ReadAll starts with, mixing multi-byte runes and invalid bytes
+	input := strings.Repeat("<Name>Zürich € \xff</Name>", 40)
+	want := strings.ReplaceAll(input, "\xff", "")
+	if len(input) <= 512 {
+		t.Fatalf("input is only %d bytes", len(input))
+	}
+
+	for _, size := range []int{1, 2, 3, 7, 511, 512} {
+		rd := NewValidUTF8Reader(strings.NewReader(input))
+		buf := make([]byte, size)
+		var got bytes.Buffer
+		for i := 0; ; i++ {
+			if i > len(input) {
+				t.Fatalf("buffer size %d: reader made no progress", size)
+			}
+			n, err := rd.Read(buf)
+			got.Write(buf[:n])
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("buffer size %d: %v", size, err)
+			}
+			if n == 0 {
+				t.Fatalf("buffer size %d: read returned no bytes and no error", size)
+			}
+		}
+		if got.String() != want {
+			t.Errorf("buffer size %d: expected %q, got %q", size, want, got.String())
+		}
+	}
+
+	// a rune that doesn't fit is split across reads
+	rd := NewValidUTF8Reader(strings.NewReader("a€"))
+	buf := make([]byte, 2)
+	if n, err := rd.Read(buf); n != 2 || err != nil || string(buf) != "a\xe2" {
+		t.Errorf("expected the first byte of the rune, got %d bytes %q, %v", n, buf[:n], err)
+	}
+	if n, err := rd.Read(buf); n != 2 || err != nil || string(buf) != "\x82\xac" {
+		t.Errorf("expected the rest of the rune, got %d bytes %q, %v", n, buf[:n], err)
+	}
+
+	// a buffer with a single byte of space left must still be filled
+	buf = make([]byte, 4)
+	n, err := NewValidUTF8Reader(strings.NewReader("abcdef")).Read(buf)
+	if n != 4 || err != nil || string(buf) != "abcd" {
+		t.Errorf("expected to fill the buffer, got %d bytes %q, %v", n, buf[:n], err)
+	}
+
+	got, err := io.ReadAll(NewValidUTF8Reader(strings.NewReader(input)))
+	if err != nil || string(got) != want {
+		t.Errorf("ReadAll: expected %d bytes, got %d, %v", len(want), len(got), err)
+	}
+}
@@ -0,0 +1,124 @@
+package ccwtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/darrenparkinson/ccw"
+)
+
+func newReplayClient(t *testing.T, fixture string) (*ccw.Client, *Transport) {
+	t.Helper()
+	rt, err := NewReplayer(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ccw.New(
+		ccw.WithCredentials("user", "pass", "id", "secret"),
+		ccw.WithHTTPClient(&http.Client{Transport: rt}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, rt
+}
+
+func Test_ReplayAcquireByDealID(t *testing.T) {
+	c, rt := newReplayClient(t, "acquire_quote.json")
+	q, err := c.QuoteService.AcquireByDealID(context.Background(), "12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.QuoteName != "Example Quote" || q.DealID != "12345678" || q.Customer.Name != "Example Customer Ltd" {
+		t.Errorf("unexpected quote header %+v", q)
+	}
+	if len(q.LineItems) != 2 || q.LineItems[0].PartNumber != "C9300-24T-E" || q.LineItems[1].ServiceDurationMonths != 36 || q.LineItems[0].ExtendedAmount != 9800 {
+		t.Errorf("unexpected quote lines %+v", q.LineItems)
+	}
+	if q.Summary.Net != 6981.6 || q.Summary.ByProductType["SERVICE"].List != 1224 {
+		t.Errorf("unexpected quote summary %+v", q.Summary)
+	}
+	if rt.Remaining() != 0 {
+		t.Errorf("expected every interaction to be replayed, %d remaining", rt.Remaining())
+	}
+
+	// the fixture only contains a single response
+	if _, err := c.QuoteService.AcquireByDealID(context.Background(), "12345678"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("expected an error once the fixture is exhausted, got %v", err)
+	}
+}
+
+func Test_ReplayListEstimate(t *testing.T) {
+	c, _ := newReplayClient(t, "list_estimate.json")
+	estimates, err := c.EstimateService.List(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(estimates) != 2 || estimates[0].EstimateID != "EST123456" || estimates[0].Amounts["TotalNetPrice"] != 6156 {
+		t.Errorf("unexpected estimates %+v", estimates)
+	}
+}
+
+func Test_Record(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"secret-token","token_type":"Bearer","expires_in":3599}`))
+			return
+		}
+		w.Write([]byte(`<Envelope><Body><ShowQuote><DataArea><Show><ResponseCriteria><ChangeStatus><Reason>Success</Reason></ChangeStatus></ResponseCriteria></Show></DataArea></ShowQuote></Body></Envelope>`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	rec := NewRecorder(path, nil)
+	c, err := ccw.New(
+		ccw.WithCredentials("user", "p@ss", "id", "client-secret"),
+		ccw.WithTokenURL(srv.URL+"/token"),
+		ccw.WithBaseURL(ccw.ServiceEstimate, srv.URL),
+		ccw.WithHTTPClient(&http.Client{Transport: rec}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.EstimateService.List(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	rt, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interactions := rt.Interactions()
+	if len(interactions) != 2 {
+		t.Fatalf("expected 2 interactions, got %d", len(interactions))
+	}
+	for _, i := range interactions {
+		for _, secret := range []string{"p%40ss", "client-secret", "secret-token", "Bearer secret-token"} {
+			if strings.Contains(i.RequestBody, secret) || strings.Contains(i.ResponseBody, secret) || i.RequestHeader.Get("Authorization") == "Bearer secret-token" {
+				t.Errorf("expected %q to be redacted from %+v", secret, i)
+			}
+		}
+	}
+
+	// the recording replays against any host
+	c, err = ccw.New(
+		ccw.WithCredentials("user", "pass", "id", "secret"),
+		ccw.WithTokenURL("http://localhost/token"),
+		ccw.WithBaseURL(ccw.ServiceEstimate, "http://localhost"),
+		ccw.WithHTTPClient(&http.Client{Transport: rt}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.EstimateService.List(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+}
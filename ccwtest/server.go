@@ -0,0 +1,421 @@
+package ccwtest
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/darrenparkinson/ccw"
+)
+
+var (
+	//go:embed templates/*
+	templateFS embed.FS
+
+	responses = template.Must(template.New("").Funcs(template.FuncMap{
+		"xml":      xmlText,
+		"date":     dateText,
+		"discount": func(t string, p float64) discount { return discount{Type: t, Percent: p} },
+	}).ParseFS(templateFS, "templates/*.xml"))
+)
+
+// Endpoint identifies one of the endpoints provided by Server.
+type Endpoint string
+
+// Endpoints
+const (
+	EndpointToken        Endpoint = "token"
+	EndpointAcquireQuote Endpoint = "AcquireQuoteService"
+	EndpointListQuote    Endpoint = "ListQuoteService"
+	EndpointListEstimate Endpoint = "listEstimate"
+)
+
+// Failure is an error that Server can be asked to return instead of a normal response.
+type Failure int
+
+// Failures
+const (
+	// FailUnauthorized responds with 401 Unauthorized, as if the token had been revoked.
+	FailUnauthorized Failure = iota + 1
+	// FailInternalError responds with 500 Internal Server Error and no body.
+	FailInternalError
+	// FailServiceUnavailable responds with 503 Service Unavailable, which the client retries.
+	FailServiceUnavailable
+	// FailSOAPFault responds with 500 Internal Server Error and a SOAP Fault.
+	FailSOAPFault
+	// FailDAQS033 responds as if no quote could be found for the deal ID.
+	FailDAQS033
+	// FailMalformedUTF8 responds normally, but with invalid UTF-8 in the body.
+	FailMalformedUTF8
+)
+
+// Server is an in-process fake of the CCW token endpoint and the quote and estimate services.
+// Quotes and estimates are seeded from Go values using AddQuote and AddEstimate, or responses
+// can be provided as XML using SetResponse.  Use Fail to inject errors.
+//
+// Requests must be authenticated with a token issued by the server, which accepts any client
+// ID and secret, and any username and password.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	tokens    map[string]bool
+	quotes    map[string][]ccw.AcquireQuoteResponse
+	estimates []ccw.ListEstimateResponseItem
+	responses map[Endpoint][]byte
+	failures  map[Endpoint][]Failure
+	requests  map[Endpoint]int
+}
+
+// NewServer starts and returns a new Server.  The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		tokens:    make(map[string]bool),
+		quotes:    make(map[string][]ccw.AcquireQuoteResponse),
+		responses: make(map[Endpoint][]byte),
+		failures:  make(map[Endpoint][]Failure),
+		requests:  make(map[Endpoint]int),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/quoting/", s.handleSOAP)
+	mux.HandleFunc("/estimate/", s.handleSOAP)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// TokenURL returns the URL of the token endpoint, for use with ccw.WithTokenURL.
+func (s *Server) TokenURL() string { return s.URL + "/token" }
+
+// QuoteBaseURL returns the base URL of the quote service, for use as QuoteService.BaseURL.
+func (s *Server) QuoteBaseURL() string { return s.URL + "/quoting" }
+
+// EstimateBaseURL returns the base URL of the estimate service, for use as EstimateService.BaseURL.
+func (s *Server) EstimateBaseURL() string { return s.URL + "/estimate" }
+
+// Options returns the options needed for a client to use the server.  Any options given are
+// appended, so may override the defaults, which authenticate with fixed credentials.
+func (s *Server) Options(opts ...ccw.Option) []ccw.Option {
+	return append([]ccw.Option{
+		ccw.WithCredentials("user", "password", "client-id", "client-secret"),
+		ccw.WithTokenURL(s.TokenURL()),
+		ccw.WithBaseURL(ccw.ServiceQuote, s.QuoteBaseURL()),
+		ccw.WithBaseURL(ccw.ServiceEstimate, s.EstimateBaseURL()),
+	}, opts...)
+}
+
+// NewClient returns a client that uses the server, configured with the given options.
+func (s *Server) NewClient(opts ...ccw.Option) (*ccw.Client, error) {
+	return ccw.New(s.Options(opts...)...)
+}
+
+// AddQuote seeds a quote, which is returned by AcquireByDealID for its deal ID and included in
+// ListByDealID.  When more than one quote is added for a deal, the first is acquired.  A
+// document ID is assigned if the quote doesn't have one.
+func (s *Server) AddQuote(q ccw.AcquireQuoteResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q.DocumentID == "" {
+		n := 0
+		for _, qs := range s.quotes {
+			n += len(qs)
+		}
+		q.DocumentID = strconv.Itoa(4700000001 + n)
+	}
+	s.quotes[q.DealID] = append(s.quotes[q.DealID], q)
+}
+
+// AddEstimate seeds an estimate, which is included in EstimateService.List.
+func (s *Server) AddEstimate(e ccw.ListEstimateResponseItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.estimates = append(s.estimates, e)
+}
+
+// SetResponse sets the XML returned for every successful request to the endpoint, instead of
+// the response generated from the seeded quotes and estimates.  This allows fixtures captured
+// from CCW to be served.
+func (s *Server) SetResponse(e Endpoint, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[e] = body
+}
+
+// Fail causes the next request to the endpoint to fail.  Failures are queued, so calling Fail
+// more than once causes that many consecutive requests to fail.
+func (s *Server) Fail(e Endpoint, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[e] = append(s.failures[e], f)
+}
+
+// Requests returns the number of requests made to the endpoint.
+func (s *Server) Requests(e Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[e]
+}
+
+// begin counts a request to the endpoint, returning the next queued failure if there is one.
+func (s *Server) begin(e Endpoint) Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[e]++
+	if len(s.failures[e]) == 0 {
+		return 0
+	}
+	f := s.failures[e][0]
+	s.failures[e] = s.failures[e][1:]
+	return f
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	f := s.begin(EndpointToken)
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if f != 0 {
+		writeTokenError(w, statusCode(f), "invalid_client", "injected failure")
+		return
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "password":
+		if r.PostForm.Get("username") == "" || r.PostForm.Get("password") == "" {
+			writeTokenError(w, http.StatusBadRequest, "invalid_grant", "missing username or password")
+			return
+		}
+	case "client_credentials":
+	default:
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type", "unsupported grant type")
+		return
+	}
+	if r.PostForm.Get("client_id") == "" || r.PostForm.Get("client_secret") == "" {
+		writeTokenError(w, http.StatusUnauthorized, "invalid_client", "missing client credentials")
+		return
+	}
+	s.mu.Lock()
+	token := fmt.Sprintf("fake-token-%d", len(s.tokens)+1)
+	s.tokens[token] = true
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   3599,
+	})
+}
+
+func writeTokenError(w http.ResponseWriter, status int, code, desc string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": desc})
+}
+
+func (s *Server) handleSOAP(w http.ResponseWriter, r *http.Request) {
+	e := Endpoint(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+	switch e {
+	case EndpointAcquireQuote, EndpointListQuote, EndpointListEstimate:
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	f := s.begin(e)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	authorized := s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	override := s.responses[e]
+	s.mu.Unlock()
+	if !authorized || f == FailUnauthorized {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var b bytes.Buffer
+	switch f {
+	case FailInternalError, FailServiceUnavailable:
+		w.WriteHeader(statusCode(f))
+		return
+	case FailSOAPFault:
+		err = responses.ExecuteTemplate(&b, "Fault_Response.xml", ccw.SOAPFault{Code: "soapenv:Server", String: "Internal Error"})
+	case FailDAQS033:
+		err = s.render(&b, e, expressions(body), &ccw.ConfigurationMessage{ID: "DAQS033", Description: "No quote found for the deal ID"})
+	default:
+		if override != nil {
+			b.Write(override)
+		} else {
+			err = s.render(&b, e, expressions(body), nil)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out := b.Bytes()
+	if f == FailMalformedUTF8 {
+		out = bytes.Replace(out, []byte("<DataArea>"), []byte("<DataArea>\xff\xfe"), 1)
+	}
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	if f == FailSOAPFault {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	w.Write(out)
+}
+
+// render writes the response for the endpoint generated from the seeded quotes and estimates.
+// If msg is not nil, it is returned as an error instead.
+func (s *Server) render(w io.Writer, e Endpoint, exprs map[string]string, msg *ccw.ConfigurationMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e {
+	case EndpointAcquireQuote:
+		quotes := s.quotes[exprs["DealId"]]
+		if len(quotes) == 0 && msg == nil {
+			msg = &ccw.ConfigurationMessage{ID: "DAQS033", Description: "No quote found for the deal ID"}
+		}
+		data := struct {
+			Quote   *ccw.AcquireQuoteResponse
+			Message *ccw.ConfigurationMessage
+		}{Message: msg}
+		if msg == nil {
+			data.Quote = &quotes[0]
+		}
+		return responses.ExecuteTemplate(w, "AcquireQuote_Response.xml", data)
+	case EndpointListQuote:
+		quotes := s.quotes[exprs["DealId"]]
+		if len(quotes) == 0 && msg == nil {
+			msg = &ccw.ConfigurationMessage{ID: "DAQS033", Description: "No quote found for the deal ID"}
+		}
+		data := struct {
+			Quotes  []ccw.ListQuoteResponseItem
+			Message *ccw.ConfigurationMessage
+		}{Message: msg}
+		if msg == nil {
+			for _, q := range quotes {
+				data.Quotes = append(data.Quotes, ccw.ListQuoteResponseItem{
+					DocumentID:  q.DocumentID,
+					QuoteName:   q.QuoteName,
+					QuoteStatus: q.QuoteStatus,
+					PriceList:   q.PriceList,
+					DealID:      q.DealID,
+					Customer:    q.Customer,
+					ExpiryDate:  dateText(q.ExpiryDate),
+				})
+			}
+		}
+		return responses.ExecuteTemplate(w, "ListQuote_Response.xml", data)
+	case EndpointListEstimate:
+		data := struct {
+			Estimates []ccw.ListEstimateResponseItem
+			Message   *ccw.ConfigurationMessage
+		}{Message: msg}
+		if msg == nil {
+			status := exprs["EstimateStatus"]
+			for _, est := range s.estimates {
+				if status == "" || status == "ALL" || est.Status == status {
+					data.Estimates = append(data.Estimates, est)
+				}
+			}
+			if max, err := strconv.Atoi(exprs["maxItems"]); err == nil && max > 0 && len(data.Estimates) > max {
+				data.Estimates = data.Estimates[:max]
+			}
+		}
+		return responses.ExecuteTemplate(w, "ListEstimate_Response.xml", data)
+	}
+	return fmt.Errorf("unknown endpoint %q", e)
+}
+
+// expressions extracts the criteria from a request, keyed by expressionLanguage, such as the
+// deal ID.  The estimate status and maximum number of items are included as "EstimateStatus"
+// and "maxItems".
+func expressions(body []byte) map[string]string {
+	exprs := make(map[string]string)
+	d := xml.NewDecoder(bytes.NewReader(body))
+	var key string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return exprs
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			key = ""
+			for _, a := range t.Attr {
+				switch {
+				case t.Name.Local == "Expression" && a.Name.Local == "expressionLanguage":
+					key = a.Value
+				case t.Name.Local == "Code" && a.Name.Local == "typeCode":
+					key = a.Value
+				case t.Name.Local == "Get" && a.Name.Local == "maxItems":
+					exprs["maxItems"] = a.Value
+				}
+			}
+		case xml.CharData:
+			if key != "" {
+				exprs[key] = strings.TrimSpace(string(t))
+				key = ""
+			}
+		case xml.EndElement:
+			key = ""
+		}
+	}
+}
+
+func statusCode(f Failure) int {
+	switch f {
+	case FailUnauthorized:
+		return http.StatusUnauthorized
+	case FailServiceUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+type discount struct {
+	Type    string
+	Percent float64
+}
+
+// dateText formats t as CCW does, or returns an empty string if t is nil.
+func dateText(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// xmlText returns the XML escaped textual representation of v, following pointers.
+func xmlText(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+	var b strings.Builder
+	if rv.IsValid() {
+		xml.EscapeText(&b, []byte(fmt.Sprint(rv.Interface())))
+	}
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package ccwtest
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/darrenparkinson/ccw"
+)
+
+func Test_Server(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	expiry := time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)
+	srv.AddQuote(ccw.AcquireQuoteResponse{
+		QuoteName:   "Branch & Campus",
+		QuoteStatus: "APPROVED",
+		DealID:      "12345678",
+		PriceList:   "Global Price List - US",
+		PriceListID: "1109",
+		Customer:    ccw.Company{Name: "Example Customer"},
+		BillTo:      ccw.BillToParty{AddressID: "100200300", Description: "Example Customer Billing", DescriptionType: "SiteName"},
+		ExpiryDate:  &expiry,
+		LineItems: []ccw.AcquireQuoteResponseItem{
+			{LineNumber: "1.0", PartNumber: "C9300-24T-E", Quantity: 2, UnitPrice: 4900, ImportCurrency: "USD", StandardDiscount: 40, ExtendedAmount: 9800, TotalAmount: 5880, Allowances: []ccw.Allowance{{Type: "Credit", Amount: 100, Currency: "USD"}}},
+			{LineNumber: "1.1", PartNumber: "CON-SNT-C93002TE", Quantity: 2, ParentLineNumber: ccw.String("1.0"), ISO8601ServiceDuration: "P0Y36M0DT0H0M"},
+		},
+	})
+	srv.AddEstimate(ccw.ListEstimateResponseItem{EstimateID: "EST1", EstimateName: "First", Status: "VALID", Amounts: map[string]float64{"TotalNetPrice": 100}})
+	srv.AddEstimate(ccw.ListEstimateResponseItem{EstimateID: "EST2", EstimateName: "Second", Status: "NOT_SUBMITTED"})
+
+	c, err := srv.NewClient(ccw.WithRetryPolicy(ccw.NoRetryPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	q, err := c.QuoteService.AcquireByDealID(ctx, "12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.QuoteName != "Branch & Campus" || q.PriceListID != "1109" || len(q.LineItems) != 2 {
+		t.Errorf("unexpected quote %+v", q)
+	}
+	if q.DocumentID != "4700000001" || q.ExpiryDate == nil || !q.ExpiryDate.Equal(expiry) || q.NetPriceProtectionDate != nil || q.BillTo.AddressID != "100200300" || q.BillTo.DescriptionType != "SiteName" {
+		t.Errorf("unexpected quote header %+v", q)
+	}
+	if !q.AllConfigurationsValid {
+		t.Error("expected all configurations to be valid")
+	}
+	if l := q.LineItems[1]; l.ParentLineNumber == nil || *l.ParentLineNumber != "1.0" || l.ServiceDurationMonths != 36 {
+		t.Errorf("unexpected quote line %+v", l)
+	}
+	if l := q.LineItems[0]; l.StandardDiscount != 40 || l.UnitPrice != 4900 || l.ImportCurrency != "USD" || l.TotalAmount != 5880 || len(l.Allowances) != 1 {
+		t.Errorf("unexpected quote line %+v", l)
+	}
+
+	quotes, err := c.QuoteService.ListByDealID(ctx, "12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 1 || quotes[0].DocumentID != q.DocumentID || quotes[0].ExpiryDate != "2023-06-30T00:00:00Z" || quotes[0].QuoteName != "Branch & Campus" {
+		t.Errorf("unexpected quotes %+v", quotes)
+	}
+	if _, err := c.QuoteService.ListByDealID(ctx, "99999999"); !errors.Is(err, ccw.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown deal, got %v", err)
+	}
+
+	estimates, err := c.EstimateService.List(ctx, &ccw.ListEstimateRequest{Status: "VALID"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(estimates) != 1 || estimates[0].EstimateID != "EST1" || estimates[0].Amounts["TotalNetPrice"] != 100 {
+		t.Errorf("unexpected estimates %+v", estimates)
+	}
+
+	tests := []struct {
+		name    string
+		failure Failure
+		check   func(error) bool
+	}{
+		{name: "unauthorized is retried with a new token", failure: FailUnauthorized, check: func(err error) bool { return err == nil }},
+		{name: "internal error", failure: FailInternalError, check: func(err error) bool { return errors.Is(err, ccw.ErrInternalError) }},
+		{name: "soap fault", failure: FailSOAPFault, check: func(err error) bool {
+			var apiErr *ccw.APIError
+			return errors.As(err, &apiErr) && apiErr.Fault != nil && apiErr.Fault.String == "Internal Error"
+		}},
+		{name: "DAQS033", failure: FailDAQS033, check: func(err error) bool { return errors.Is(err, ccw.ErrNotFound) }},
+		{name: "malformed utf-8", failure: FailMalformedUTF8, check: func(err error) bool { return err == nil }},
+	}
+	for _, tc := range tests {
+		srv.Fail(EndpointAcquireQuote, tc.failure)
+		_, err := c.QuoteService.AcquireByDealID(ctx, "12345678")
+		if !tc.check(err) {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+	}
+
+	srv.Fail(EndpointListEstimate, FailDAQS033)
+	if _, err := c.EstimateService.List(ctx, nil); !errors.Is(err, ccw.ErrNotFound) {
+		t.Errorf("expected ErrNotFound listing estimates, got %v", err)
+	}
+	if _, err := c.EstimateService.List(ctx, nil); err != nil {
+		t.Errorf("expected the failure to apply to a single request, got %v", err)
+	}
+	if n := srv.Requests(EndpointToken); n != 2 {
+		t.Errorf("expected 2 token requests, got %d", n)
+	}
+}
+
+func Test_ServerSetResponse(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	// serve the body recorded in the replay fixture
+	rt, err := NewReplayer(filepath.Join("testdata", "list_estimate.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetResponse(EndpointListEstimate, []byte(rt.Interactions()[1].ResponseBody))
+
+	c, err := srv.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	estimates, err := c.EstimateService.List(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(estimates) != 2 || estimates[1].EstimateName != "Campus core" {
+		t.Errorf("unexpected estimates %+v", estimates)
+	}
+}
@@ -24,7 +24,7 @@ func main() {
 	mustMapEnv(&password, "CCW_PASSWORD")
 	mustMapEnv(&clientID, "CCW_CLIENTID")
 	mustMapEnv(&clientSecret, "CCW_CLIENTSECRET")
-	c, err := ccw.NewClient(username, password, clientID, clientSecret, nil)
+	c, err := ccw.New(ccw.WithCredentials(username, password, clientID, clientSecret))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -5,7 +5,9 @@ import (
 	"encoding/csv"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/darrenparkinson/ccw"
 )
@@ -17,7 +19,24 @@ func main() {
 	mustMapEnv(&password, "CCW_PASSWORD")
 	mustMapEnv(&clientID, "CCW_CLIENTID")
 	mustMapEnv(&clientSecret, "CCW_CLIENTSECRET")
-	c, err := ccw.NewClient(username, password, clientID, clientSecret, nil)
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	// cache the token on disk so repeated runs of the cli don't each request a new one
+	ts, err := ccw.NewFileCacheTokenSource(&ccw.PasswordTokenSource{
+		Username:     username,
+		Password:     password,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		HTTPClient:   httpClient,
+	}, os.Getenv("CCW_TOKEN_CACHE_DIR"), username, clientID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := []ccw.Option{ccw.WithTokenSource(ts), ccw.WithHTTPClient(httpClient)}
+	if dir := os.Getenv("CCW_TRACE_DIR"); dir != "" {
+		opts = append(opts, ccw.WithTrace(ccw.TraceDir(dir)))
+	}
+	c, err := ccw.New(opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -34,7 +53,7 @@ func main() {
 	}
 
 	csvExport("export.csv", data)
-	// err = c.EstimateService.List(context.Background())
+	// estimates, err := c.EstimateService.List(context.Background(), nil)
 	// if err != nil {
 	// 	log.Fatal(err)
 	// }
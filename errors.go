@@ -1,5 +1,11 @@
 package ccw
 
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
 // Err implements the error interface so we can have constant errors.
 type Err string
 
@@ -9,10 +15,93 @@ func (e Err) Error() string {
 
 // Error Constants
 const (
-	ErrBadRequest    = Err("ccw: bad request")
-	ErrUnauthorized  = Err("ccw: unauthorized request")
-	ErrForbidden     = Err("ccw: forbidden")
-	ErrInternalError = Err("ccw: internal error")
-	ErrUnknown       = Err("ccw: unexpected error occurred")
-	ErrNotFound      = Err("ccw: not found")
+	ErrBadRequest         = Err("ccw: bad request")
+	ErrUnauthorized       = Err("ccw: unauthorized request")
+	ErrForbidden          = Err("ccw: forbidden")
+	ErrTooManyRequests    = Err("ccw: too many requests")
+	ErrInternalError      = Err("ccw: internal error")
+	ErrServiceUnavailable = Err("ccw: service unavailable")
+	ErrUnknown            = Err("ccw: unexpected error occurred")
+	ErrNotFound           = Err("ccw: not found")
+
+	ErrExpired              = Err("ccw: expired")
+	ErrInvalidConfiguration = Err("ccw: invalid configuration")
 )
+
+// APIError represents an error returned by CCW, either as an unsuccessful HTTP status or as
+// a message within an otherwise successful response.  Where the error corresponds to one of the
+// error constants, it can be checked using errors.Is, e.g. errors.Is(err, ccw.ErrNotFound).
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// MessageID is the CCW message ID, e.g. DAQS033.
+	MessageID string
+	// Description is the description of the message returned by CCW.
+	Description string
+	// Reason is the reason given for the message, if any.
+	Reason string
+	// ChangeStatus is the reason given in the response's ChangeStatus, e.g. "Failure".
+	ChangeStatus string
+	// Fault is the SOAP Fault returned by CCW, if any.
+	Fault *SOAPFault
+	// EBMSErrors are any ebMS error signals returned by CCW.
+	EBMSErrors []EBMSError
+	// Body is the raw response body.
+	Body []byte
+	// Err is the matching error constant, if any.
+	Err error
+
+	retryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	msg := "ccw: request failed"
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if e.MessageID != "" {
+		msg += ": " + e.MessageID
+	} else if e.ChangeStatus != "" {
+		msg += ": " + e.ChangeStatus
+	}
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	if e.Fault != nil {
+		msg += ": " + e.Fault.Error()
+	}
+	for _, ebmsErr := range e.EBMSErrors {
+		msg += ": " + ebmsErr.Error()
+	}
+	if e.MessageID == "" && e.ChangeStatus == "" && e.Description == "" && e.Fault == nil && len(e.EBMSErrors) == 0 && e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	return msg
+}
+
+// Unwrap returns the matching error constant so that errors.Is can be used with an APIError.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// messageError returns an APIError for a message returned by CCW within a successful response.
+// The matching error constant is taken from the registered message ID, or the description, and is
+// ErrUnknown if the message has no category.
+func messageError(id, description, reason, changeStatus string, body []byte) *APIError {
+	e := &APIError{
+		StatusCode:   http.StatusOK,
+		MessageID:    id,
+		Description:  description,
+		Reason:       reason,
+		ChangeStatus: changeStatus,
+		Body:         body,
+	}
+	category := categorize(description)
+	if info, ok := LookupMessage(id); ok {
+		category = info.Category
+	}
+	if e.Err = category.Err(); e.Err == nil {
+		e.Err = ErrUnknown
+	}
+	return e
+}
@@ -0,0 +1,189 @@
+package ccw
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func Test_APIError(t *testing.T) {
+	tests := []struct {
+		err    *APIError
+		target error
+		want   string
+	}{
+		{err: messageError("DAQS033", "Quote not found", "", "Failure", nil), target: ErrNotFound, want: "ccw: not found: DAQS033: Quote not found"},
+		{err: messageError("ABC123", "Something else", "", "Failure", nil), target: ErrUnknown, want: "ccw: unexpected error occurred: ABC123: Something else"},
+		{err: &APIError{StatusCode: 403, Err: ErrForbidden}, target: ErrForbidden, want: "ccw: forbidden (status 403)"},
+	}
+
+	for _, tc := range tests {
+		if tc.err.Error() != tc.want {
+			t.Errorf("expected %q, got %q", tc.want, tc.err.Error())
+		}
+		if tc.target != nil && !errors.Is(tc.err, tc.target) {
+			t.Errorf("expected %q to match %v", tc.err, tc.target)
+		}
+		if tc.target != ErrNotFound && errors.Is(tc.err, ErrNotFound) {
+			t.Errorf("expected %q not to match ErrNotFound", tc.err)
+		}
+	}
+}
+
+func Test_MakeXMLRequestReturnsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request body"))
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.makeXMLRequest(context.Background(), nil, srv.URL, []byte("<x/>"), &struct{}{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || string(apiErr.Body) != "bad request body" || !errors.Is(err, ErrBadRequest) {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+}
+
+func Test_MessageCatalogue(t *testing.T) {
+	m := newConfigurationMessage("", "DAQS033", "Quote not found", "", false)
+	if m.Severity != SeverityError || m.Category != CategoryNotFound {
+		t.Errorf("unexpected classification %+v", m)
+	}
+
+	m = newConfigurationMessage("1.0", "UNKNOWN1", "Something happened", "", true)
+	if m.Severity != SeverityWarning || m.Category != CategoryOther {
+		t.Errorf("expected unknown messages alongside success to be warnings, got %+v", m)
+	}
+
+	RegisterMessage("TEST001", MessageInfo{Severity: SeverityError, Category: CategoryExpired})
+	if err := messageError("TEST001", "Quote has expired", "", "Failure", nil); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected registered message to match ErrExpired, got %v", err)
+	}
+}
+
+func Test_MessageCategories(t *testing.T) {
+	tests := []struct {
+		id, description string
+		want            MessageCategory
+		wantErr         error
+	}{
+		{id: "DAQS033", description: "Quote not found", want: CategoryNotFound, wantErr: ErrNotFound},
+		{id: "X1", description: "No estimate found for the ID", want: CategoryNotFound, wantErr: ErrNotFound},
+		{id: "X2", description: "User is not authorized to access this deal", want: CategoryAccessDenied, wantErr: ErrForbidden},
+		{id: "X3", description: "The quote has Expired", want: CategoryExpired, wantErr: ErrExpired},
+		{id: "X4", description: "Line 1.0 has an invalid configuration", want: CategoryInvalidConfiguration, wantErr: ErrInvalidConfiguration},
+		{id: "X5", description: "Something happened", want: CategoryOther, wantErr: ErrUnknown},
+	}
+	for _, tc := range tests {
+		if m := newConfigurationMessage("", tc.id, tc.description, "", false); m.Category != tc.want || m.Severity != SeverityError {
+			t.Errorf("%s: expected an error in category %q, got %+v", tc.id, tc.want, m)
+		}
+		err := messageError(tc.id, tc.description, "", "Failure", nil)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: expected %v, got %v", tc.id, tc.wantErr, err)
+		}
+	}
+}
+
+func Test_ResponseError(t *testing.T) {
+	notFound := newConfigurationMessage("", "DAQS033", "No quote found", "", false)
+	other := newConfigurationMessage("", "X9", "Something happened", "", false)
+
+	if err := responseError("Success", "", []ConfigurationMessage{notFound}, nil); err != nil {
+		t.Errorf("expected no error for a successful response, got %v", err)
+	}
+	// a registered message is preferred to the text of the ChangeStatus
+	err := responseError("Failure", "Request failed", []ConfigurationMessage{other, notFound}, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.MessageID != "DAQS033" || !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the registered message, got %v", err)
+	}
+	if err := responseError("Failure", "  Request failed\n", []ConfigurationMessage{other}, nil); !errors.As(err, &apiErr) || apiErr.Description != "Request failed" {
+		t.Errorf("expected the ChangeStatus text, got %v", err)
+	}
+	if err := responseError("Failure", "", []ConfigurationMessage{other}, nil); !errors.As(err, &apiErr) || apiErr.MessageID != "X9" {
+		t.Errorf("expected the only message, got %v", err)
+	}
+	if err := responseError("", "", nil, nil); !errors.Is(err, ErrUnknown) {
+		t.Errorf("expected ErrUnknown, got %v", err)
+	}
+}
+
+func Test_ListClassifiesMessages(t *testing.T) {
+	listQuote := `<Envelope><Body><ShowQuote><DataArea><Quote><QuoteHeader>
+<UserArea><CiscoExtensions><CiscoHeader><ConfigurationMessages>
+	<ID>X2</ID><Description>User is not authorized to access this deal</Description>
+</ConfigurationMessages></CiscoHeader></CiscoExtensions></UserArea>
+</QuoteHeader></Quote></DataArea></ShowQuote></Body></Envelope>`
+	c := newTestClient(t, listQuote, nil)
+	if _, err := c.QuoteService.ListByDealID(context.Background(), "12345"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected ErrForbidden listing quotes, got %v", err)
+	}
+
+	listEstimate := `<Envelope><Body><ShowQuote><DataArea>
+<Show><ResponseCriteria><ChangeStatus><Reason>Failure</Reason></ChangeStatus></ResponseCriteria></Show>
+<Quote><QuoteHeader><Message><ID>DAQS033</ID><Description>No records</Description></Message></QuoteHeader></Quote>
+</DataArea></ShowQuote></Body></Envelope>`
+	c = newTestClient(t, listEstimate, nil)
+	if _, err := c.EstimateService.List(context.Background(), nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound listing estimates, got %v", err)
+	}
+}
+
+func Test_ListReturnsWarnings(t *testing.T) {
+	listQuote := `<Envelope><Body><ShowQuote><DataArea><Quote><QuoteHeader>
+<DocumentID><ID>4712345678</ID></DocumentID>
+<UserArea><CiscoExtensions><CiscoHeader>
+	<ConfigurationMessages><ID>W1</ID><Description>Quote has expired</Description></ConfigurationMessages>
+	<ConfigurationMessages><ID>W2</ID><Description>Pricing is indicative</Description></ConfigurationMessages>
+</CiscoHeader></CiscoExtensions></UserArea>
+</QuoteHeader></Quote></DataArea></ShowQuote></Body></Envelope>`
+	logger := &captureLogger{}
+	c := newTestClient(t, listQuote, nil)
+	c.logger = logger
+	quotes, err := c.QuoteService.ListByDealID(context.Background(), "12345")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 1 || len(quotes[0].Messages) != 2 {
+		t.Fatalf("expected a quote with 2 messages, got %+v", quotes)
+	}
+	if m := quotes[0].Messages[0]; m.ID != "W1" || m.Severity != SeverityWarning || m.Category != CategoryExpired {
+		t.Errorf("unexpected message %+v", m)
+	}
+	if ids, _ := logger.attr("ccw operation finished", "message_ids"); !reflect.DeepEqual(ids, []string{"W1", "W2"}) {
+		t.Errorf("expected the message IDs to be logged, got %v", ids)
+	}
+
+	listEstimate := `<Envelope><Body><ShowQuote><DataArea>
+<Show><ResponseCriteria><ChangeStatus><Reason>Success</Reason></ChangeStatus></ResponseCriteria></Show>
+<Quote><QuoteHeader><ID>EST1</ID><Message><ID>W3</ID><Description>Pricing is indicative</Description></Message></QuoteHeader></Quote>
+<Quote><QuoteHeader><ID>EST2</ID></QuoteHeader></Quote>
+</DataArea></ShowQuote></Body></Envelope>`
+	logger = &captureLogger{}
+	c = newTestClient(t, listEstimate, nil)
+	c.logger = logger
+	estimates, err := c.EstimateService.List(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(estimates) != 2 || len(estimates[0].Messages) != 1 || estimates[1].Messages != nil {
+		t.Fatalf("expected only the first estimate to have a message, got %+v", estimates)
+	}
+	if m := estimates[0].Messages[0]; m.ID != "W3" || m.Severity != SeverityWarning {
+		t.Errorf("unexpected message %+v", m)
+	}
+	if ids, _ := logger.attr("ccw operation finished", "message_ids"); !reflect.DeepEqual(ids, []string{"W3"}) {
+		t.Errorf("expected the message IDs to be logged, got %v", ids)
+	}
+}
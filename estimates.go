@@ -5,52 +5,381 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
-	"net/http"
-	"strings"
-	"text/template"
+	"strconv"
+	"time"
 )
 
+// ListEstimateRequest holds the criteria used to filter and sort the results of EstimateService.List.
+// Any fields left empty will use the defaults described below.
 type ListEstimateRequest struct {
-	Description string
+	// FromDate is the start of the period to search, defaulting to one year before ToDate.
+	FromDate time.Time
+	// ToDate is the end of the period to search, defaulting to now.
+	ToDate time.Time
+	// SortBy is the field to sort by, defaulting to "LAST_MODIFIED".
+	SortBy string
+	// SortOrder is either "ASC" or "DESC", defaulting to "DESC".
+	SortOrder string
+	// Status is the estimate status code to filter on, defaulting to "ALL".
+	Status string
+	// MaxItems is the maximum number of estimates to return, defaulting to 25.
+	MaxItems int
 }
 
-func (s *EstimateService) List(ctx context.Context) error {
+// ListEstimateResponseItem is a summary of a single estimate returned by EstimateService.List.
+type ListEstimateResponseItem struct {
+	EstimateID               string             `json:"estimateId"`
+	EstimateName             string             `json:"estimateName"`
+	Status                   string             `json:"status"`
+	PriceList                string             `json:"priceList"`
+	DocumentDateTime         string             `json:"documentDateTime"`
+	LastModificationDateTime string             `json:"lastModificationDateTime"`
+	Amounts                  map[string]float64 `json:"amounts,omitempty"`
+	// Messages holds any warnings returned with the estimate.
+	Messages []ConfigurationMessage `json:"messages,omitempty"`
+}
+
+// List returns a summary of each of the estimates matching the given criteria.  A nil
+// request will use the defaults described on ListEstimateRequest.
+func (s *EstimateService) List(ctx context.Context, r *ListEstimateRequest) (estimates []ListEstimateResponseItem, err error) {
+	ctx, op := s.client.startOperation(ctx, "ListEstimate")
+	defer func() {
+		var msgs []ConfigurationMessage
+		for _, e := range estimates {
+			msgs = append(msgs, e.Messages...)
+		}
+		op.end(err, msgs)
+	}()
 
 	// 1. Load the template
-	template, err := template.ParseFS(templates, "templates/ListEstimate_Request.xml")
+	template, err := lookupTemplate("ListEstimate_Request.xml")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// 2. Create the data for the template
-	data := ListEstimateRequest{
-		Description: "Hello World",
+	data := ListEstimateRequest{}
+	if r != nil {
+		data = *r
+	}
+	if data.ToDate.IsZero() {
+		data.ToDate = time.Now()
+	}
+	if data.FromDate.IsZero() {
+		data.FromDate = data.ToDate.AddDate(-1, 0, 0)
+	}
+	if data.SortBy == "" {
+		data.SortBy = "LAST_MODIFIED"
+	}
+	if data.SortOrder == "" {
+		data.SortOrder = "DESC"
+	}
+	if data.Status == "" {
+		data.Status = "ALL"
+	}
+	if data.MaxItems <= 0 {
+		data.MaxItems = 25
 	}
 	// 3. Apply the data to the template
 	var tpl bytes.Buffer
 	if err := template.Execute(&tpl, data); err != nil {
-		return err
+		return nil, err
 	}
 
 	// 4. Send the data
-
 	qurl := fmt.Sprintf("%s/listEstimate", s.BaseURL)
-	fmt.Println(qurl)
-	req, err := http.NewRequest("POST", qurl, strings.NewReader(tpl.String()))
+
+	var resp ListEstimateXMLResponse
+	raw, err := s.client.makeXMLRequest(ctx, s.limiter(), qurl, tpl.Bytes(), &resp)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	req.Header.Add("Accept", "application/xml")
-	req.Header.Add("Content-Type", "application/xml")
-	var resp ListEstimateXMLResponse
-	err = s.client.makeXMLRequest(ctx, req, &resp)
+
+	changeStatus := resp.Body.ShowQuote.DataArea.Show.ResponseCriteria.ChangeStatus
+	success := changeStatus.Reason == "Success"
+	msgs := make([][]ConfigurationMessage, len(resp.Body.ShowQuote.DataArea.Quote))
+	var all []ConfigurationMessage
+	for i, quote := range resp.Body.ShowQuote.DataArea.Quote {
+		message := quote.QuoteHeader.Message
+		if message.Description != "" {
+			msgs[i] = []ConfigurationMessage{newConfigurationMessage("", message.ID, message.Description, "", success)}
+			all = append(all, msgs[i]...)
+		}
+	}
+	if err := responseError(changeStatus.Reason, changeStatus.Text, all, raw); err != nil {
+		return nil, err
+	}
+
+	// 5. Format the response
+	estimates = []ListEstimateResponseItem{}
+	for i, quote := range resp.Body.ShowQuote.DataArea.Quote {
+		quoteHeader := quote.QuoteHeader
+		if quoteHeader.ID == "" {
+			continue
+		}
+		le := ListEstimateResponseItem{
+			EstimateID:               quoteHeader.ID,
+			Status:                   quoteHeader.Status.Code.Text,
+			DocumentDateTime:         quoteHeader.DocumentDateTime,
+			LastModificationDateTime: quoteHeader.LastModificationDateTime,
+			Messages:                 msgs[i],
+		}
+		for _, d := range quoteHeader.Description {
+			if d.Text != "" && (d.Type == "" || d.Type == "EstimateName") {
+				le.EstimateName = d.Text
+			}
+		}
+		if quoteHeader.UserArea.CiscoExtensions.CiscoHeader.PriceList.Description != "" {
+			le.PriceList = quoteHeader.UserArea.CiscoExtensions.CiscoHeader.PriceList.Description
+		}
+		for _, amount := range quoteHeader.Extension.Amount {
+			if amount.TypeCode == "" {
+				continue
+			}
+			if le.Amounts == nil {
+				le.Amounts = make(map[string]float64)
+			}
+			v, _ := strconv.ParseFloat(amount.Text, 64)
+			le.Amounts[amount.TypeCode] = v
+		}
+		estimates = append(estimates, le)
+	}
+	return estimates, nil
+}
+
+// AcquireEstimateRequest holds the data used to render the acquire estimate request.
+type AcquireEstimateRequest struct {
+	EstimateID string
+}
+
+// AcquireEstimateResponse represents a single estimate and its line items.  Line items
+// use the same model as quotes so that both can be treated in the same way.
+type AcquireEstimateResponse struct {
+	EstimateID   string                     `json:"estimateId"`
+	EstimateName string                     `json:"estimateName"`
+	Status       string                     `json:"status"`
+	PriceList    string                     `json:"priceList"`
+	PriceListID  string                     `json:"priceListId"`
+	LineItems    []AcquireQuoteResponseItem `json:"items"`
+	Messages     []ConfigurationMessage     `json:"messages,omitempty"`
+}
+
+// Acquire retrieves the full details of the estimate with the given ID, including its line items.
+func (s *EstimateService) Acquire(ctx context.Context, estimateID string) (aer *AcquireEstimateResponse, err error) {
+	ctx, op := s.client.startOperation(ctx, "AcquireEstimate", "estimate_id", estimateID)
+	defer func() {
+		var msgs []ConfigurationMessage
+		if aer != nil {
+			msgs = aer.Messages
+		}
+		op.end(err, msgs)
+	}()
+
+	if estimateID == "" {
+		return nil, fmt.Errorf("%w: missing estimate id", ErrBadRequest)
+	}
+
+	// 1. Load the template
+	template, err := lookupTemplate("AcquireEstimate_Request.xml")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("%+v\n", resp)
-	fmt.Println(resp.Body.ShowQuote.DataArea.Show.ResponseCriteria.ChangeStatus.Reason)
-	fmt.Println(resp.Body.ShowQuote.DataArea.Quote.QuoteHeader.Message.Description)
-	fmt.Println(resp.Body.ShowQuote.DataArea.Quote.QuoteHeader.Message.Extension.ValueText.TypeCode)
-	return nil
+	// 2. Create the data for the template
+	data := AcquireEstimateRequest{EstimateID: estimateID}
+
+	// 3. Apply the data to the template
+	var tpl bytes.Buffer
+	if err := template.Execute(&tpl, data); err != nil {
+		return nil, err
+	}
+
+	// 4. Make the request
+	qurl := fmt.Sprintf("%s/acquireEstimate", s.BaseURL)
+
+	// Estimates are returned in the same ShowQuote structure as quotes.
+	var resp AcquireQuoteXMLResponse
+	raw, err := s.client.makeXMLRequest(ctx, s.limiter(), qurl, tpl.Bytes(), &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resp.err(raw); err != nil {
+		return nil, err
+	}
+
+	// 5. Format the response
+	quoteHeader := resp.Body.ShowQuote.DataArea.Quote.QuoteHeader
+	aer = &AcquireEstimateResponse{
+		EstimateID: quoteHeader.DocumentID.ID,
+		Status:     quoteHeader.Status.Code.Text,
+	}
+	if aer.EstimateID == "" {
+		aer.EstimateID = estimateID
+	}
+	switch quoteHeader.Extension.ValueText.TypeCode {
+	case "EstimateName", "QuoteName":
+		aer.EstimateName = quoteHeader.Extension.ValueText.Text
+	}
+	for _, p := range quoteHeader.UserArea.CiscoExtensions.CiscoHeader.PriceList {
+		if p.Description != "" && p.ID != "" {
+			aer.PriceList = p.Description
+			aer.PriceListID = p.ID
+		}
+	}
+	aer.LineItems = resp.lineItems()
+	aer.Messages = resp.messages()
+
+	return aer, nil
+}
+
+// EstimateRequest describes the contents of an estimate to be created or updated.
+type EstimateRequest struct {
+	Name      string
+	PriceList string
+	Lines     []EstimateLine
+}
+
+// EstimateLine describes a single line item on an estimate.  LineNumber is optional
+// and will default to the position of the line in the request, e.g. "1.0".  Set
+// ParentLineNumber to nest a line beneath another, for example a service under its
+// hardware.
+type EstimateLine struct {
+	LineNumber            string
+	PartNumber            string
+	Quantity              int64
+	ServiceDurationMonths float64
+	RequestedStartDate    time.Time
+	ParentLineNumber      string
+}
+
+// ProcessEstimateResponse is returned when an estimate is created or updated.
+type ProcessEstimateResponse struct {
+	EstimateID string                 `json:"estimateId"`
+	Messages   []ConfigurationMessage `json:"messages,omitempty"`
+}
+
+// processEstimateTemplateData is the data used to render the process estimate template.
+type processEstimateTemplateData struct {
+	ActionCode string
+	EstimateID string
+	Name       string
+	PriceList  string
+	Lines      []processEstimateTemplateLine
+}
+
+type processEstimateTemplateLine struct {
+	LineNumber         string
+	PartNumber         string
+	Quantity           int64
+	ServiceDuration    string
+	RequestedStartDate string
+	ParentLineNumber   string
+}
+
+// Create creates a new estimate from the given line items, returning the new estimate ID and
+// any configuration messages.
+func (s *EstimateService) Create(ctx context.Context, r *EstimateRequest) (*ProcessEstimateResponse, error) {
+	return s.process(ctx, "createEstimate", "Add", "", r)
+}
+
+// Update replaces the contents of the estimate with the given ID using the given line items,
+// returning any configuration messages.
+func (s *EstimateService) Update(ctx context.Context, estimateID string, r *EstimateRequest) (*ProcessEstimateResponse, error) {
+	if estimateID == "" {
+		return nil, fmt.Errorf("%w: missing estimate id", ErrBadRequest)
+	}
+	return s.process(ctx, "updateEstimate", "Replace", estimateID, r)
+}
+
+func (s *EstimateService) process(ctx context.Context, endpoint, actionCode, estimateID string, r *EstimateRequest) (per *ProcessEstimateResponse, err error) {
+	ctx, op := s.client.startOperation(withoutRetries(ctx), endpoint, "estimate_id", estimateID)
+	defer func() {
+		var msgs []ConfigurationMessage
+		if per != nil {
+			msgs = per.Messages
+		}
+		op.end(err, msgs)
+	}()
+
+	if r == nil || len(r.Lines) == 0 {
+		return nil, fmt.Errorf("%w: at least one line is required", ErrBadRequest)
+	}
+
+	// 1. Load the template
+	template, err := lookupTemplate("ProcessEstimate_Request.xml")
+	if err != nil {
+		return nil, err
+	}
+	// 2. Create the data for the template
+	data := processEstimateTemplateData{
+		ActionCode: actionCode,
+		EstimateID: estimateID,
+		Name:       r.Name,
+		PriceList:  r.PriceList,
+	}
+	for i, line := range r.Lines {
+		if line.PartNumber == "" || line.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: line %d requires a part number and quantity", ErrBadRequest, i+1)
+		}
+		tl := processEstimateTemplateLine{
+			LineNumber:       line.LineNumber,
+			PartNumber:       line.PartNumber,
+			Quantity:         line.Quantity,
+			ParentLineNumber: line.ParentLineNumber,
+		}
+		if tl.LineNumber == "" {
+			tl.LineNumber = fmt.Sprintf("%d.0", i+1)
+		}
+		if line.ServiceDurationMonths > 0 {
+			tl.ServiceDuration = monthsToISODuration(line.ServiceDurationMonths)
+		}
+		if !line.RequestedStartDate.IsZero() {
+			tl.RequestedStartDate = line.RequestedStartDate.Format("2006-01-02")
+		}
+		data.Lines = append(data.Lines, tl)
+	}
+
+	// 3. Apply the data to the template
+	var tpl bytes.Buffer
+	if err := template.Execute(&tpl, data); err != nil {
+		return nil, err
+	}
+
+	// 4. Make the request
+	qurl := fmt.Sprintf("%s/%s", s.BaseURL, endpoint)
+
+	var resp ProcessEstimateXMLResponse
+	raw, err := s.client.makeXMLRequest(ctx, s.limiter(), qurl, tpl.Bytes(), &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	// 5. Format the response
+	quote := resp.Body.AcknowledgeQuote.DataArea.Quote
+	per = &ProcessEstimateResponse{EstimateID: quote.QuoteHeader.ID}
+	changeStatus := resp.Body.AcknowledgeQuote.DataArea.Acknowledge.ResponseCriteria.ChangeStatus
+	success := changeStatus.Reason == "Success"
+	for _, m := range quote.QuoteHeader.UserArea.CiscoExtensions.CiscoHeader.ConfigurationMessages {
+		if m.ID != "" || m.Description != "" {
+			per.Messages = append(per.Messages, newConfigurationMessage("", m.ID, m.Description, m.Reason, success))
+		}
+	}
+	for _, line := range quote.QuoteLine {
+		for _, m := range line.UserArea.CiscoExtensions.CiscoLine.ConfigurationMessages {
+			if m.ID != "" || m.Description != "" {
+				per.Messages = append(per.Messages, newConfigurationMessage(line.LineNumber, m.ID, m.Description, m.Reason, success))
+			}
+		}
+	}
+	// the ChangeStatus alone determines whether the request succeeded, since CCW may echo the
+	// estimate ID even when it fails
+	if !success {
+		return per, responseError(changeStatus.Reason, changeStatus.Text, per.Messages, raw)
+	}
+	if per.EstimateID == "" {
+		per.EstimateID = estimateID
+	}
+
+	return per, nil
 }
 
 type ListEstimateXMLResponse struct {
@@ -157,10 +486,46 @@ type ListEstimateXMLResponse struct {
 						} `xml:"ChangeStatus"`
 					} `xml:"ResponseCriteria"`
 				} `xml:"Show"`
-				Quote struct {
+				Quote []struct {
 					Text        string `xml:",chardata"`
 					QuoteHeader struct {
-						Text    string `xml:",chardata"`
+						Text                     string `xml:",chardata"`
+						ID                       string `xml:"ID"`
+						DocumentDateTime         string `xml:"DocumentDateTime"`
+						LastModificationDateTime string `xml:"LastModificationDateTime"`
+						Description              []struct {
+							Text string `xml:",chardata"`
+							Type string `xml:"type,attr"`
+						} `xml:"Description"`
+						Status struct {
+							Text string `xml:",chardata"`
+							Code struct {
+								Text     string `xml:",chardata"`
+								TypeCode string `xml:"typeCode,attr"`
+							} `xml:"Code"`
+						} `xml:"Status"`
+						Extension struct {
+							Text   string `xml:",chardata"`
+							Amount []struct {
+								Text       string `xml:",chardata"`
+								TypeCode   string `xml:"typeCode,attr"`
+								CurrencyID string `xml:"currencyID,attr"`
+							} `xml:"Amount"`
+						} `xml:"Extension"`
+						UserArea struct {
+							Text            string `xml:",chardata"`
+							CiscoExtensions struct {
+								Text        string `xml:",chardata"`
+								CiscoHeader struct {
+									Text      string `xml:",chardata"`
+									PriceList struct {
+										Text        string `xml:",chardata"`
+										ID          string `xml:"ID"`
+										Description string `xml:"Description"`
+									} `xml:"PriceList"`
+								} `xml:"CiscoHeader"`
+							} `xml:"CiscoExtensions"`
+						} `xml:"UserArea"`
 						Message struct {
 							Text        string `xml:",chardata"`
 							ID          string `xml:"ID"`
@@ -179,3 +544,75 @@ type ListEstimateXMLResponse struct {
 		} `xml:"ShowQuote"`
 	} `xml:"Body"`
 }
+
+type ProcessEstimateXMLResponse struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Text    string   `xml:",chardata"`
+	Soapenv string   `xml:"soapenv,attr"`
+	Body    struct {
+		Text             string `xml:",chardata"`
+		AcknowledgeQuote struct {
+			Text            string `xml:",chardata"`
+			Xmlns           string `xml:"xmlns,attr"`
+			ApplicationArea struct {
+				Text             string `xml:",chardata"`
+				CreationDateTime string `xml:"CreationDateTime"`
+				BODID            string `xml:"BODID"`
+			} `xml:"ApplicationArea"`
+			DataArea struct {
+				Text        string `xml:",chardata"`
+				Acknowledge struct {
+					Text             string `xml:",chardata"`
+					ResponseCriteria struct {
+						Text         string `xml:",chardata"`
+						ChangeStatus struct {
+							Text   string `xml:",chardata"`
+							Reason string `xml:"Reason"`
+						} `xml:"ChangeStatus"`
+					} `xml:"ResponseCriteria"`
+				} `xml:"Acknowledge"`
+				Quote struct {
+					Text        string `xml:",chardata"`
+					QuoteHeader struct {
+						Text     string `xml:",chardata"`
+						ID       string `xml:"ID"`
+						UserArea struct {
+							Text            string `xml:",chardata"`
+							CiscoExtensions struct {
+								Text        string `xml:",chardata"`
+								CiscoHeader struct {
+									Text                  string `xml:",chardata"`
+									ConfigurationMessages []struct {
+										Text        string `xml:",chardata"`
+										ID          string `xml:"ID"`
+										Description string `xml:"Description"`
+										Reason      string `xml:"Reason"`
+									} `xml:"ConfigurationMessages"`
+								} `xml:"CiscoHeader"`
+							} `xml:"CiscoExtensions"`
+						} `xml:"UserArea"`
+					} `xml:"QuoteHeader"`
+					QuoteLine []struct {
+						Text       string `xml:",chardata"`
+						LineNumber string `xml:"LineNumber"`
+						UserArea   struct {
+							Text            string `xml:",chardata"`
+							CiscoExtensions struct {
+								Text      string `xml:",chardata"`
+								CiscoLine struct {
+									Text                  string `xml:",chardata"`
+									ConfigurationMessages []struct {
+										Text        string `xml:",chardata"`
+										ID          string `xml:"ID"`
+										Description string `xml:"Description"`
+										Reason      string `xml:"Reason"`
+									} `xml:"ConfigurationMessages"`
+								} `xml:"CiscoLine"`
+							} `xml:"CiscoExtensions"`
+						} `xml:"UserArea"`
+					} `xml:"QuoteLine"`
+				} `xml:"Quote"`
+			} `xml:"DataArea"`
+		} `xml:"AcknowledgeQuote"`
+	} `xml:"Body"`
+}
@@ -0,0 +1,333 @@
+package ccw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client whose quote and estimate services use a test server that
+// responds to every request with the given body.  The bodies of the requests made are appended
+// to requests, if it isn't nil.
+func newTestClient(t *testing.T, body string, requests *[]string) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if requests != nil {
+			*requests = append(*requests, string(b))
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	c, err := New(
+		WithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"})),
+		WithBaseURL(ServiceQuote, srv.URL),
+		WithBaseURL(ServiceEstimate, srv.URL),
+		WithRetryPolicy(NoRetryPolicy),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func processEstimateXML(reason, estimateID, messages string) string {
+	return `<Envelope><Body><AcknowledgeQuote><DataArea>
+<Acknowledge><ResponseCriteria><ChangeStatus><Reason>` + reason + `</Reason></ChangeStatus></ResponseCriteria></Acknowledge>
+<Quote>
+	<QuoteHeader>
+		<ID>` + estimateID + `</ID>
+		<UserArea><CiscoExtensions><CiscoHeader>` + messages + `</CiscoHeader></CiscoExtensions></UserArea>
+	</QuoteHeader>
+</Quote>
+</DataArea></AcknowledgeQuote></Body></Envelope>`
+}
+
+func Test_ProcessEstimate(t *testing.T) {
+	request := &EstimateRequest{
+		Name: "Branch refresh",
+		Lines: []EstimateLine{
+			{PartNumber: "C9300-24T-E", Quantity: 2},
+			{PartNumber: "CON-SNT-C93002TE", Quantity: 2, ServiceDurationMonths: 36, ParentLineNumber: "1.0"},
+		},
+	}
+	message := `<ConfigurationMessages><ID>EST001</ID><Description>Line 1.0 is invalid</Description></ConfigurationMessages>`
+
+	tests := []struct {
+		name       string
+		update     bool
+		response   string
+		wantID     string
+		wantAction string
+		wantErr    bool
+	}{
+		{name: "create", response: processEstimateXML("Success", "EST123", ""), wantID: "EST123", wantAction: `actionCode="Add"`},
+		{name: "create failure echoing an id", response: processEstimateXML("Failure", "EST123", message), wantAction: `actionCode="Add"`, wantErr: true},
+		{name: "update", update: true, response: processEstimateXML("Success", "", ""), wantID: "EST999", wantAction: `actionCode="Replace"`},
+		{name: "update failure", update: true, response: processEstimateXML("Failure", "", message), wantAction: `actionCode="Replace"`, wantErr: true},
+		{name: "failure without messages", response: processEstimateXML("Failure", "", ""), wantAction: `actionCode="Add"`, wantErr: true},
+	}
+	for _, tc := range tests {
+		var requests []string
+		c := newTestClient(t, tc.response, &requests)
+		var per *ProcessEstimateResponse
+		var err error
+		if tc.update {
+			per, err = c.EstimateService.Update(context.Background(), "EST999", request)
+		} else {
+			per, err = c.EstimateService.Create(context.Background(), request)
+		}
+
+		if tc.wantErr {
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.ChangeStatus != "Failure" {
+				t.Errorf("%s: expected an APIError with a failed change status, got %v", tc.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if per.EstimateID != tc.wantID {
+			t.Errorf("%s: expected estimate ID %q, got %q", tc.name, tc.wantID, per.EstimateID)
+		}
+
+		if len(requests) != 1 {
+			t.Fatalf("%s: expected 1 request, got %d", tc.name, len(requests))
+		}
+		for _, want := range []string{tc.wantAction, "C9300-24T-E", "CON-SNT-C93002TE", "P0Y36M0DT0H0M"} {
+			if !strings.Contains(requests[0], want) {
+				t.Errorf("%s: expected the request to contain %q", tc.name, want)
+			}
+		}
+		if tc.update && !strings.Contains(requests[0], "EST999") {
+			t.Errorf("%s: expected the request to contain the estimate ID", tc.name)
+		}
+	}
+
+	c := newTestClient(t, processEstimateXML("Success", "EST123", ""), nil)
+	if _, err := c.EstimateService.Create(context.Background(), &EstimateRequest{}); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("expected ErrBadRequest without lines, got %v", err)
+	}
+	if _, err := c.EstimateService.Update(context.Background(), "", request); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("expected ErrBadRequest without an estimate ID, got %v", err)
+	}
+}
+
+const listEstimateXML = `<Envelope><Body><ShowQuote><DataArea>
+<Show><ResponseCriteria><ChangeStatus><Reason>Success</Reason></ChangeStatus></ResponseCriteria></Show>
+<Quote>
+	<QuoteHeader>
+		<ID>EST123456</ID>
+		<DocumentDateTime>2023-03-01T09:30:00Z</DocumentDateTime>
+		<LastModificationDateTime>2023-03-02T14:45:00Z</LastModificationDateTime>
+		<Description type="EstimateName">Branch refresh</Description>
+		<Status><Code typeCode="EstimateStatus">VALID</Code></Status>
+		<Extension>
+			<Amount typeCode="TotalListPrice" currencyID="USD">10260.00</Amount>
+			<Amount typeCode="TotalNetPrice" currencyID="USD">6156.00</Amount>
+		</Extension>
+		<UserArea><CiscoExtensions><CiscoHeader>
+			<PriceList><ID>1109</ID><Description>Global Price List - US</Description></PriceList>
+		</CiscoHeader></CiscoExtensions></UserArea>
+	</QuoteHeader>
+</Quote>
+<Quote>
+	<QuoteHeader>
+		<ID>EST123457</ID>
+		<Description type="EstimateName">Campus core</Description>
+		<Status><Code typeCode="EstimateStatus">NOT_SUBMITTED</Code></Status>
+	</QuoteHeader>
+</Quote>
+<Quote><QuoteHeader></QuoteHeader></Quote>
+</DataArea></ShowQuote></Body></Envelope>`
+
+// listEstimateCriteria extracts the criteria rendered into a list estimate request.
+func listEstimateCriteria(body string) map[string]string {
+	criteria := map[string]string{}
+	for _, m := range regexp.MustCompile(`expressionLanguage="(\w+)">([^<]*)<`).FindAllStringSubmatch(body, -1) {
+		criteria[m[1]] = m[2]
+	}
+	if m := regexp.MustCompile(`maxItems="(\d+)"`).FindStringSubmatch(body); m != nil {
+		criteria["maxItems"] = m[1]
+	}
+	if m := regexp.MustCompile(`typeCode="EstimateStatus">([^<]*)<`).FindStringSubmatch(body); m != nil {
+		criteria["EstimateStatus"] = m[1]
+	}
+	return criteria
+}
+
+func Test_ListEstimates(t *testing.T) {
+	var requests []string
+	c := newTestClient(t, listEstimateXML, &requests)
+	estimates, err := c.EstimateService.List(context.Background(), &ListEstimateRequest{
+		FromDate:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		ToDate:    time.Date(2023, 3, 31, 12, 0, 0, 0, time.FixedZone("EST", -5*60*60)),
+		SortBy:    "CREATED",
+		SortOrder: "ASC",
+		Status:    "VALID",
+		MaxItems:  10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ListEstimateResponseItem{
+		{
+			EstimateID:               "EST123456",
+			EstimateName:             "Branch refresh",
+			Status:                   "VALID",
+			PriceList:                "Global Price List - US",
+			DocumentDateTime:         "2023-03-01T09:30:00Z",
+			LastModificationDateTime: "2023-03-02T14:45:00Z",
+			Amounts:                  map[string]float64{"TotalListPrice": 10260, "TotalNetPrice": 6156},
+		},
+		{EstimateID: "EST123457", EstimateName: "Campus core", Status: "NOT_SUBMITTED"},
+	}
+	if !reflect.DeepEqual(estimates, want) {
+		t.Errorf("expected %+v, got %+v", want, estimates)
+	}
+
+	wantCriteria := map[string]string{
+		"FromDate":       "2023-01-01T00:00:00Z",
+		"ToDate":         "2023-03-31T17:00:00Z",
+		"SortBy":         "CREATED",
+		"SortOrder":      "ASC",
+		"EstimateStatus": "VALID",
+		"maxItems":       "10",
+	}
+	if got := listEstimateCriteria(requests[0]); !reflect.DeepEqual(got, wantCriteria) {
+		t.Errorf("expected criteria %v, got %v", wantCriteria, got)
+	}
+}
+
+func Test_ListEstimatesDefaults(t *testing.T) {
+	var requests []string
+	c := newTestClient(t, listEstimateXML, &requests)
+	if _, err := c.EstimateService.List(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	toDate := time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC)
+	if _, err := c.EstimateService.List(context.Background(), &ListEstimateRequest{ToDate: toDate}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := listEstimateCriteria(requests[0])
+	for key, want := range map[string]string{"SortBy": "LAST_MODIFIED", "SortOrder": "DESC", "EstimateStatus": "ALL", "maxItems": "25"} {
+		if got[key] != want {
+			t.Errorf("expected %s to default to %q, got %q", key, want, got[key])
+		}
+	}
+	to, err := time.Parse(time.RFC3339, got["ToDate"])
+	if err != nil || time.Since(to) > time.Minute {
+		t.Errorf("expected ToDate to default to now, got %q", got["ToDate"])
+	}
+	if from, err := time.Parse(time.RFC3339, got["FromDate"]); err != nil || !from.Equal(to.AddDate(-1, 0, 0)) {
+		t.Errorf("expected FromDate to default to a year before ToDate, got %q", got["FromDate"])
+	}
+
+	got = listEstimateCriteria(requests[1])
+	if got["FromDate"] != "2022-03-31T00:00:00Z" || got["ToDate"] != "2023-03-31T00:00:00Z" {
+		t.Errorf("expected FromDate to default to a year before the given ToDate, got %v", got)
+	}
+}
+
+const acquireEstimateXML = `<Envelope><Body><ShowQuote><DataArea>
+<Show><ResponseCriteria><ChangeStatus><Reason>Success</Reason></ChangeStatus></ResponseCriteria></Show>
+<Quote>
+	<QuoteHeader>
+		<DocumentID><ID>EST123456</ID></DocumentID>
+		<Status><Code>VALID</Code></Status>
+		<UserArea><CiscoExtensions><CiscoHeader>
+			<PriceList><ID>1109</ID><Description>Global Price List - US</Description></PriceList>
+		</CiscoHeader></CiscoExtensions></UserArea>
+		<Extension><ValueText typeCode="EstimateName">Branch refresh</ValueText></Extension>
+	</QuoteHeader>
+	<QuoteLine>
+		<LineNumber>1.0</LineNumber>
+		<Item>
+			<ItemID><ID>C9300-24T-E</ID></ItemID>
+			<Description>Catalyst 9300 24-port</Description>
+			<Classification><Type listName="ProductType">HARDWARE</Type></Classification>
+			<Specification>
+				<Property><NameValue name="CCWLineNumber">1.0</NameValue></Property>
+				<Property>
+					<NameValue name="BundleIndicator">N</NameValue>
+					<Effectivity><Type>LeadTime</Type><EffectiveTimePeriod><Duration>P0Y0M14DT0H0M</Duration></EffectiveTimePeriod></Effectivity>
+				</Property>
+			</Specification>
+		</Item>
+		<Quantity>2</Quantity>
+		<UnitPrice><Amount currencyID="USD">4900.00</Amount></UnitPrice>
+		<PaymentTerm>
+			<Discount><Type>StandardDiscount</Type><DiscountPercent>40</DiscountPercent></Discount>
+			<Discount><Type>EffectiveDiscount</Type><DiscountPercent>41.5</DiscountPercent></Discount>
+		</PaymentTerm>
+	</QuoteLine>
+	<QuoteLine>
+		<LineNumber>1.1</LineNumber>
+		<Item>
+			<ItemID><ID>CON-SNT-C93002TE</ID></ItemID>
+			<Description>SNTC-8X5XNBD</Description>
+			<Description type="ServiceType">SNT</Description>
+			<Specification>
+				<Property><ParentID>1.0</ParentID><NameValue name="CCWLineNumber">1.1</NameValue></Property>
+				<Property>
+					<NameValue name="BundleIndicator">N</NameValue>
+					<Effectivity><Type>ServiceDuration</Type><EffectiveTimePeriod><Duration>P0Y36M0DT0H0M</Duration></EffectiveTimePeriod></Effectivity>
+				</Property>
+			</Specification>
+		</Item>
+		<Quantity>2</Quantity>
+		<PaymentTerm>
+			<Discount><Type>ContractualDiscount</Type><DiscountPercent>10</DiscountPercent></Discount>
+		</PaymentTerm>
+	</QuoteLine>
+</Quote>
+</DataArea></ShowQuote></Body></Envelope>`
+
+func Test_AcquireEstimate(t *testing.T) {
+	var requests []string
+	c := newTestClient(t, acquireEstimateXML, &requests)
+	aer, err := c.EstimateService.Acquire(context.Background(), "EST123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 || !strings.Contains(requests[0], "EST123456") {
+		t.Errorf("expected a request for the estimate ID, got %q", requests)
+	}
+	if aer.EstimateID != "EST123456" || aer.EstimateName != "Branch refresh" || aer.Status != "VALID" || aer.PriceListID != "1109" {
+		t.Errorf("unexpected estimate %+v", aer)
+	}
+	if len(aer.LineItems) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(aer.LineItems))
+	}
+
+	hw, svc := aer.LineItems[0], aer.LineItems[1]
+	if hw.ParentLineNumber != nil || hw.ISO8601LeadTime != "P0Y0M14DT0H0M" || hw.LeadTimeDays != 14 || hw.ProductTypeClassification != "HARDWARE" {
+		t.Errorf("unexpected hardware line %+v", hw)
+	}
+	if hw.StandardDiscount != 40 || hw.EffectiveDiscount != 41.5 || hw.UnitPrice != 4900 || hw.Quantity != 2 {
+		t.Errorf("unexpected hardware pricing %+v", hw)
+	}
+	if svc.ParentLineNumber == nil || *svc.ParentLineNumber != "1.0" || svc.ServiceDurationMonths != 36 || svc.ServiceType != "SNT" || svc.ContractualDiscount != 10 {
+		t.Errorf("unexpected service line %+v", svc)
+	}
+
+	// estimates use the same line item model as quotes
+	aqr, err := newTestClient(t, acquireEstimateXML, nil).QuoteService.AcquireByDealID(context.Background(), "12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(aer.LineItems, aqr.LineItems) {
+		t.Errorf("expected the same line items as a quote, got %+v and %+v", aer.LineItems, aqr.LineItems)
+	}
+
+	if _, err := c.EstimateService.Acquire(context.Background(), ""); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("expected ErrBadRequest without an estimate ID, got %v", err)
+	}
+}
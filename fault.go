@@ -0,0 +1,172 @@
+package ccw
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// SOAPFault represents a SOAP 1.1 or 1.2 Fault returned by CCW.
+type SOAPFault struct {
+	// Code is the faultcode (SOAP 1.1) or Code/Value (SOAP 1.2), e.g. "soapenv:Client".
+	Code string
+	// Subcode is the Code/Subcode/Value (SOAP 1.2 only).
+	Subcode string
+	// String is the faultstring (SOAP 1.1) or Reason/Text (SOAP 1.2).
+	String string
+	// Actor is the faultactor (SOAP 1.1) or Role (SOAP 1.2).
+	Actor string
+	// Detail is the raw XML content of the detail element.
+	Detail string
+}
+
+func (f *SOAPFault) Error() string {
+	msg := "soap fault"
+	if f.Code != "" {
+		msg += ": " + f.Code
+	}
+	if f.Subcode != "" {
+		msg += ": " + f.Subcode
+	}
+	if f.String != "" {
+		msg += ": " + f.String
+	}
+	return msg
+}
+
+// isClient reports whether the fault was caused by the request rather than by the server.
+func (f *SOAPFault) isClient() bool {
+	code := f.Code[strings.LastIndex(f.Code, ":")+1:]
+	return code == "Client" || code == "Sender"
+}
+
+// EBMSError represents an ebMS error signal returned by CCW in the message header.
+type EBMSError struct {
+	ErrorCode        string
+	Severity         string
+	Category         string
+	ShortDescription string
+	Description      string
+	Detail           string
+	RefToMessageID   string
+}
+
+func (e EBMSError) Error() string {
+	msg := "ebms error"
+	if e.ErrorCode != "" {
+		msg += ": " + e.ErrorCode
+	}
+	if e.ShortDescription != "" {
+		msg += ": " + e.ShortDescription
+	}
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// hasEBMSFailure reports whether any of the ebMS errors have a severity of failure, rather than warning.
+func hasEBMSFailure(errs []EBMSError) bool {
+	for _, e := range errs {
+		if strings.EqualFold(e.Severity, "failure") {
+			return true
+		}
+	}
+	return false
+}
+
+// faultXMLResponse contains only the parts of an envelope used to report errors.  Element names
+// are matched regardless of namespace, so it decodes both SOAP 1.1 and 1.2 envelopes.
+type faultXMLResponse struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Header  struct {
+		Messaging struct {
+			SignalMessage []struct {
+				Error []struct {
+					ErrorCode           string `xml:"errorCode,attr"`
+					Severity            string `xml:"severity,attr"`
+					Category            string `xml:"category,attr"`
+					ShortDescription    string `xml:"shortDescription,attr"`
+					RefToMessageInError string `xml:"refToMessageInError,attr"`
+					Description         string `xml:"Description"`
+					ErrorDetail         string `xml:"ErrorDetail"`
+				} `xml:"Error"`
+			} `xml:"SignalMessage"`
+		} `xml:"Messaging"`
+	} `xml:"Header"`
+	Body struct {
+		Fault *struct {
+			// SOAP 1.1
+			FaultCode   string `xml:"faultcode"`
+			FaultString string `xml:"faultstring"`
+			FaultActor  string `xml:"faultactor"`
+			FaultDetail struct {
+				Inner string `xml:",innerxml"`
+			} `xml:"detail"`
+			// SOAP 1.2
+			Code struct {
+				Value   string `xml:"Value"`
+				Subcode struct {
+					Value string `xml:"Value"`
+				} `xml:"Subcode"`
+			} `xml:"Code"`
+			Reason struct {
+				Text []string `xml:"Text"`
+			} `xml:"Reason"`
+			Role   string `xml:"Role"`
+			Detail struct {
+				Inner string `xml:",innerxml"`
+			} `xml:"Detail"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+// parseFault looks for a SOAP Fault and any ebMS error signals in the response body.  It returns
+// nil values if the body doesn't contain either, or can't be parsed.
+func parseFault(raw []byte) (*SOAPFault, []EBMSError) {
+	if !bytes.Contains(raw, []byte("Fault")) && !bytes.Contains(raw, []byte("SignalMessage")) {
+		return nil, nil
+	}
+	var resp faultXMLResponse
+	if err := xml.Unmarshal(raw, &resp); err != nil {
+		return nil, nil
+	}
+	var ebmsErrors []EBMSError
+	for _, signal := range resp.Header.Messaging.SignalMessage {
+		for _, e := range signal.Error {
+			ebmsErrors = append(ebmsErrors, EBMSError{
+				ErrorCode:        e.ErrorCode,
+				Severity:         e.Severity,
+				Category:         e.Category,
+				ShortDescription: e.ShortDescription,
+				Description:      strings.TrimSpace(e.Description),
+				Detail:           strings.TrimSpace(e.ErrorDetail),
+				RefToMessageID:   e.RefToMessageInError,
+			})
+		}
+	}
+	f := resp.Body.Fault
+	if f == nil {
+		return nil, ebmsErrors
+	}
+	fault := &SOAPFault{
+		Code:    strings.TrimSpace(f.FaultCode),
+		String:  strings.TrimSpace(f.FaultString),
+		Actor:   strings.TrimSpace(f.FaultActor),
+		Detail:  strings.TrimSpace(f.FaultDetail.Inner),
+		Subcode: strings.TrimSpace(f.Code.Subcode.Value),
+	}
+	if fault.Code == "" {
+		fault.Code = strings.TrimSpace(f.Code.Value)
+	}
+	if fault.String == "" && len(f.Reason.Text) > 0 {
+		fault.String = strings.TrimSpace(f.Reason.Text[0])
+	}
+	if fault.Actor == "" {
+		fault.Actor = strings.TrimSpace(f.Role)
+	}
+	if fault.Detail == "" {
+		fault.Detail = strings.TrimSpace(f.Detail.Inner)
+	}
+	return fault, ebmsErrors
+}
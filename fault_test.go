@@ -0,0 +1,118 @@
+package ccw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_ParseFault(t *testing.T) {
+	soap11 := `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/">
+	<soapenv:Body>
+		<soapenv:Fault>
+			<faultcode>soapenv:Client</faultcode>
+			<faultstring>Invalid Deal ID</faultstring>
+			<detail><code>QWS001</code></detail>
+		</soapenv:Fault>
+	</soapenv:Body>
+</soapenv:Envelope>`
+	soap12 := `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope">
+	<env:Body>
+		<env:Fault>
+			<env:Code><env:Value>env:Receiver</env:Value><env:Subcode><env:Value>m:Timeout</env:Value></env:Subcode></env:Code>
+			<env:Reason><env:Text xml:lang="en">Backend timed out</env:Text></env:Reason>
+			<env:Detail><m:info xmlns:m="urn:m">retry later</m:info></env:Detail>
+		</env:Fault>
+	</env:Body>
+</env:Envelope>`
+	ebms := `<S12:Envelope xmlns:S12="http://www.w3.org/2003/05/soap-envelope" xmlns:eb="http://docs.oasis-open.org/ebxml-msg/ebms/v3.0/ns/core/200704/">
+	<S12:Header>
+		<eb:Messaging>
+			<eb:SignalMessage>
+				<eb:Error errorCode="EBMS:0004" severity="failure" category="Content" shortDescription="Other">
+					<eb:Description xml:lang="en">Schema validation failed</eb:Description>
+				</eb:Error>
+			</eb:SignalMessage>
+		</eb:Messaging>
+	</S12:Header>
+	<S12:Body/>
+</S12:Envelope>`
+
+	fault, _ := parseFault([]byte(soap11))
+	if fault == nil || fault.Code != "soapenv:Client" || fault.String != "Invalid Deal ID" || fault.Detail != "<code>QWS001</code>" || !fault.isClient() {
+		t.Errorf("unexpected SOAP 1.1 fault %+v", fault)
+	}
+
+	fault, _ = parseFault([]byte(soap12))
+	if fault == nil || fault.Code != "env:Receiver" || fault.Subcode != "m:Timeout" || fault.String != "Backend timed out" || fault.isClient() {
+		t.Errorf("unexpected SOAP 1.2 fault %+v", fault)
+	}
+
+	fault, ebmsErrors := parseFault([]byte(ebms))
+	if fault != nil || len(ebmsErrors) != 1 || ebmsErrors[0].ErrorCode != "EBMS:0004" || ebmsErrors[0].Description != "Schema validation failed" || !hasEBMSFailure(ebmsErrors) {
+		t.Errorf("unexpected ebMS errors %+v, %+v", fault, ebmsErrors)
+	}
+
+	if fault, ebmsErrors := parseFault([]byte(`<Envelope><Body>ok</Body></Envelope>`)); fault != nil || ebmsErrors != nil {
+		t.Errorf("expected no fault, got %+v, %+v", fault, ebmsErrors)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(soap11))
+	}))
+	defer srv.Close()
+	c, err := NewClientWithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.makeXMLRequest(context.Background(), nil, srv.URL, []byte("<x/>"), &struct{}{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Fault == nil || !errors.Is(err, ErrBadRequest) {
+		t.Errorf("expected a bad request with a fault, got %v", err)
+	}
+}
+
+func Test_ClientFaultWithServerError(t *testing.T) {
+	fault := `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/">
+	<soapenv:Body>
+		<soapenv:Fault>
+			<faultcode>soapenv:%s</faultcode>
+			<faultstring>Invalid Deal ID</faultstring>
+		</soapenv:Fault>
+	</soapenv:Body>
+</soapenv:Envelope>`
+	tests := []struct {
+		code      string
+		wantErr   error
+		wantCalls int
+	}{
+		{code: "Client", wantErr: ErrBadRequest, wantCalls: 1},
+		{code: "Server", wantErr: ErrInternalError, wantCalls: 3},
+	}
+	for _, tc := range tests {
+		var calls int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, fault, tc.code)
+		}))
+		c, err := NewClientWithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"}), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.RetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+		_, err = c.makeXMLRequest(context.Background(), nil, srv.URL, []byte("<x/>"), &struct{}{})
+		srv.Close()
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError || !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s fault: expected %v, got %v", tc.code, tc.wantErr, err)
+		}
+		if calls != tc.wantCalls {
+			t.Errorf("%s fault: expected %d attempts, got %d", tc.code, tc.wantCalls, calls)
+		}
+	}
+}
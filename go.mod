@@ -1,10 +1,23 @@
 module github.com/darrenparkinson/ccw
 
-go 1.18
+go 1.19
 
 require (
 	github.com/rs/cors v1.8.2
 	golang.org/x/time v0.0.0-20220411224347-583f2d630306
 )
 
-require github.com/gorilla/mux v1.8.0
+require (
+	github.com/gorilla/mux v1.8.0
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/metric v1.16.0
+	go.opentelemetry.io/otel/sdk v1.16.0
+	go.opentelemetry.io/otel/sdk/metric v0.39.0
+	go.opentelemetry.io/otel/trace v1.16.0
+)
+
+require (
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+)
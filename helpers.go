@@ -2,9 +2,11 @@ package ccw
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 func isoDurationToMonthsFloat(isoDuration string) (float64, error) {
@@ -61,3 +63,55 @@ func isoDurationToDaysFloat(isoDuration string) (float64, error) {
 
 	return math.Round(days*100) / 100, nil
 }
+
+// monthsToISODuration converts a number of months into an ISO8601 duration in the format
+// used by CCW, e.g. P0Y12M0DT0H0M.  Part months are converted to days on the basis of 30
+// days per month, the same as isoDurationToMonthsFloat.
+func monthsToISODuration(months float64) string {
+	whole := math.Floor(months)
+	days := math.Round((months - whole) * 30)
+	return fmt.Sprintf("P0Y%dM%dDT0H0M", int64(whole), int64(days))
+}
+
+// parseFloatOrNil parses s, returning nil if it is empty or not a number.  Unlike FloatOrNil,
+// zero is returned as a value.
+func parseFloatOrNil(s string) *float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// parseIntOrNil parses s, returning nil if it is empty or not a whole number.  Whole numbers
+// written with a decimal point, such as "2.0", are accepted.
+func parseIntOrNil(s string) *int64 {
+	f := parseFloatOrNil(s)
+	if f == nil || *f != math.Trunc(*f) {
+		return nil
+	}
+	v := int64(*f)
+	return &v
+}
+
+// parseIndicator reports whether an indicator returned by CCW, such as "Y" or "true", is set.
+func parseIndicator(s string) bool {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "Y", "YES", "TRUE", "1":
+		return true
+	}
+	return false
+}
+
+// validateDealID ensures a deal ID is present and numeric before it is sent to CCW.
+func validateDealID(dealID string) error {
+	if dealID == "" {
+		return fmt.Errorf("%w: missing deal id", ErrBadRequest)
+	}
+	for _, r := range dealID {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("%w: invalid deal id %q, must be numeric", ErrBadRequest, dealID)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package ccw
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func Test_IsoDurationToMonthsFloat(t *testing.T) {
 	type test struct {
@@ -47,3 +50,48 @@ func Test_IsoDurationToDaysFloat(t *testing.T) {
 		}
 	}
 }
+
+func Test_MonthsToISODuration(t *testing.T) {
+	type test struct {
+		input float64
+		want  string
+	}
+
+	tests := []test{
+		{input: 0, want: "P0Y0M0DT0H0M"},
+		{input: 12, want: "P0Y12M0DT0H0M"},
+		{input: 26.5, want: "P0Y26M15DT0H0M"},
+	}
+
+	for _, tc := range tests {
+		got := monthsToISODuration(tc.input)
+		if got != tc.want {
+			t.Errorf("exptected: %v, got: %v", tc.want, got)
+		}
+	}
+}
+
+func Test_ValidateDealID(t *testing.T) {
+	type test struct {
+		input string
+		valid bool
+	}
+
+	tests := []test{
+		{input: "123456", valid: true},
+		{input: "", valid: false},
+		{input: "12a456", valid: false},
+		{input: "123</ns1:Expression><x>", valid: false},
+		{input: "1&2", valid: false},
+	}
+
+	for _, tc := range tests {
+		err := validateDealID(tc.input)
+		if tc.valid && err != nil {
+			t.Errorf("expected %q to be valid, got: %v", tc.input, err)
+		}
+		if !tc.valid && !errors.Is(err, ErrBadRequest) {
+			t.Errorf("expected %q to be invalid, got: %v", tc.input, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package ccw
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Logger is the interface used by the client for logging.  Each method takes a message followed
+// by alternating keys and values, the same as log/slog, so a *slog.Logger can be used directly.
+// The client only logs at debug level, and logs nothing unless a logger is provided using
+// WithLogger.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// nopLogger discards everything, so the library never writes to stdout or stderr by default.
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+// operation tracks a single call to a CCW service, such as acquiring a quote, which may be made
+// up of several HTTP requests.
+type operation struct {
+	c     *Client
+	name  string
+	attrs []interface{}
+	start time.Time
+	span  trace.Span
+}
+
+// startOperation logs the start of an operation and starts a span for it.  attrs are alternating
+// keys and values describing the operation, such as the deal ID.  The returned context should be
+// used for the requests made by the operation.
+func (c *Client) startOperation(ctx context.Context, name string, attrs ...interface{}) (context.Context, *operation) {
+	op := &operation{
+		c:     c,
+		name:  name,
+		attrs: attrs,
+		start: time.Now(),
+	}
+	ctx = context.WithValue(ctx, operationKey{}, name)
+	ctx, op.span = c.telemetry.tracer.Start(ctx, "ccw."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(operationAttributes(name, attrs)...),
+	)
+	c.logger.Debug("ccw operation started", op.args()...)
+	return ctx, op
+}
+
+// end logs the result of the operation and ends its span, including how long it took and any CCW
+// message IDs returned either as an error or alongside a successful response.
+func (op *operation) end(err error, msgs []ConfigurationMessage) {
+	defer op.span.End()
+	args := append(op.args(), "duration", time.Since(op.start))
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		args = append(args, "status", apiErr.StatusCode)
+		op.span.SetAttributes(attribute.Int("http.status_code", apiErr.StatusCode))
+		if apiErr.MessageID != "" {
+			args = append(args, "message_id", apiErr.MessageID)
+			op.span.SetAttributes(attribute.String("ccw.message_id", apiErr.MessageID))
+		}
+	}
+	if len(msgs) > 0 {
+		ids := make([]string, 0, len(msgs))
+		for _, m := range msgs {
+			ids = append(ids, m.ID)
+		}
+		args = append(args, "message_ids", ids)
+		op.span.SetAttributes(attribute.StringSlice("ccw.message_ids", ids))
+	}
+	if err != nil {
+		op.span.RecordError(err)
+		op.span.SetStatus(codes.Error, err.Error())
+		op.c.logger.Debug("ccw operation failed", append(args, "error", err)...)
+		return
+	}
+	op.c.logger.Debug("ccw operation finished", args...)
+}
+
+func (op *operation) args() []interface{} {
+	args := make([]interface{}, 0, len(op.attrs)+6)
+	args = append(args, "operation", op.name)
+	return append(args, op.attrs...)
+}
@@ -0,0 +1,72 @@
+package ccw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type captureLogger struct {
+	msgs []string
+	args [][]interface{}
+}
+
+func (l *captureLogger) Debug(msg string, args ...interface{}) {
+	l.msgs = append(l.msgs, msg)
+	l.args = append(l.args, args)
+}
+func (l *captureLogger) Info(msg string, args ...interface{})  { l.Debug(msg, args...) }
+func (l *captureLogger) Warn(msg string, args ...interface{})  { l.Debug(msg, args...) }
+func (l *captureLogger) Error(msg string, args ...interface{}) { l.Debug(msg, args...) }
+
+func (l *captureLogger) attr(msg, key string) (interface{}, bool) {
+	for i, m := range l.msgs {
+		if m != msg {
+			continue
+		}
+		for j := 0; j+1 < len(l.args[i]); j += 2 {
+			if l.args[i][j] == key {
+				return l.args[i][j+1], true
+			}
+		}
+	}
+	return nil, false
+}
+
+func Test_Logger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	logger := &captureLogger{}
+	c, err := New(
+		WithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"})),
+		WithBaseURL(ServiceQuote, srv.URL),
+		WithRetryPolicy(NoRetryPolicy),
+		WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.QuoteService.AcquireByDealID(context.Background(), "12345"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	tests := []struct {
+		msg, key string
+		want     interface{}
+	}{
+		{msg: "ccw operation started", key: "deal_id", want: "12345"},
+		{msg: "ccw request finished", key: "status", want: http.StatusBadRequest},
+		{msg: "ccw operation failed", key: "operation", want: "AcquireQuote"},
+		{msg: "ccw operation failed", key: "status", want: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		got, ok := logger.attr(tc.msg, tc.key)
+		if !ok || got != tc.want {
+			t.Errorf("%s: expected %s=%v, got %v (logged: %v)", tc.msg, tc.key, tc.want, got, logger.msgs)
+		}
+	}
+}
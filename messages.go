@@ -0,0 +1,166 @@
+package ccw
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// MessageSeverity indicates whether a CCW message is an error, a warning or purely informational.
+type MessageSeverity string
+
+// Message severities
+const (
+	SeverityError   MessageSeverity = "error"
+	SeverityWarning MessageSeverity = "warning"
+	SeverityInfo    MessageSeverity = "info"
+)
+
+// MessageCategory groups CCW messages by the type of problem they describe.
+type MessageCategory string
+
+// Message categories
+const (
+	CategoryNotFound             MessageCategory = "not found"
+	CategoryAccessDenied         MessageCategory = "access denied"
+	CategoryExpired              MessageCategory = "expired"
+	CategoryInvalidConfiguration MessageCategory = "invalid configuration"
+	CategoryOther                MessageCategory = "other"
+)
+
+// Err returns the error constant that corresponds to the category, or nil if there isn't one.
+func (c MessageCategory) Err() error {
+	switch c {
+	case CategoryNotFound:
+		return ErrNotFound
+	case CategoryAccessDenied:
+		return ErrForbidden
+	case CategoryExpired:
+		return ErrExpired
+	case CategoryInvalidConfiguration:
+		return ErrInvalidConfiguration
+	}
+	return nil
+}
+
+// MessageInfo describes a registered CCW message ID.
+type MessageInfo struct {
+	Severity    MessageSeverity
+	Category    MessageCategory
+	Description string
+}
+
+// ConfigurationMessage represents a message returned by CCW about the configuration
+// of a quote or estimate, optionally relating to a specific line.  Severity and Category
+// are taken from the registered message ID, see RegisterMessage, or from the description.
+type ConfigurationMessage struct {
+	LineNumber  string          `json:"lineNumber,omitempty"`
+	ID          string          `json:"id"`
+	Description string          `json:"description"`
+	Reason      string          `json:"reason,omitempty"`
+	Severity    MessageSeverity `json:"severity"`
+	Category    MessageCategory `json:"category"`
+}
+
+// messages holds the registered message IDs.  Only DAQS033 is registered by default; other
+// messages are classified by their description.
+var (
+	messagesMu sync.RWMutex
+	messages   = map[string]MessageInfo{
+		"DAQS033": {Severity: SeverityError, Category: CategoryNotFound, Description: "No quote was found for the deal ID"},
+	}
+)
+
+// messagePatterns classify messages whose IDs aren't registered by their description, so that
+// each category can be recognised without knowing every ID CCW may return.  The first
+// category with a matching phrase is used.
+var messagePatterns = []struct {
+	category MessageCategory
+	phrases  []string
+}{
+	{CategoryAccessDenied, []string{"not authorized", "not authorised", "unauthorized", "access denied", "not entitled", "do not have access", "does not have access", "permission"}},
+	{CategoryExpired, []string{"expired", "no longer valid"}},
+	{CategoryInvalidConfiguration, []string{"invalid configuration", "configuration is invalid", "configuration is not valid", "configuration error", "not orderable"}},
+	{CategoryNotFound, []string{"not found", "no quote found", "no estimate found", "does not exist", "no records found"}},
+}
+
+// LookupMessage returns the registered information for the given CCW message ID.
+func LookupMessage(id string) (MessageInfo, bool) {
+	messagesMu.RLock()
+	defer messagesMu.RUnlock()
+	info, ok := messages[id]
+	return info, ok
+}
+
+// RegisterMessage adds or replaces the information for the given CCW message ID, so that it is
+// classified consistently rather than by its description.
+func RegisterMessage(id string, info MessageInfo) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	messages[id] = info
+}
+
+// newConfigurationMessage classifies a message returned by CCW using its registered ID, or its
+// description if the ID isn't registered.  Messages that aren't registered are treated as errors, unless they were returned alongside a successful
+// status in which case they are treated as warnings.
+func newConfigurationMessage(lineNumber, id, description, reason string, success bool) ConfigurationMessage {
+	m := ConfigurationMessage{
+		LineNumber:  lineNumber,
+		ID:          id,
+		Description: description,
+		Reason:      reason,
+		Severity:    SeverityError,
+		Category:    CategoryOther,
+	}
+	if success {
+		m.Severity = SeverityWarning
+	}
+	if info, ok := LookupMessage(id); ok {
+		m.Severity = info.Severity
+		m.Category = info.Category
+	} else {
+		m.Category = categorize(description)
+	}
+	return m
+}
+
+// categorize returns the category of an unregistered message based on its description.
+func categorize(description string) MessageCategory {
+	description = strings.ToLower(description)
+	for _, p := range messagePatterns {
+		for _, phrase := range p.phrases {
+			if strings.Contains(description, phrase) {
+				return p.category
+			}
+		}
+	}
+	return CategoryOther
+}
+
+// responseError returns an error for a response whose ChangeStatus reason isn't "Success", or
+// nil if it is.  Messages with a known category are preferred, so that the error can be checked
+// with errors.Is, followed by the text of the ChangeStatus, then messages classed as errors and
+// finally any other message.
+func responseError(reason, text string, msgs []ConfigurationMessage, raw []byte) error {
+	if reason == "Success" {
+		return nil
+	}
+	for _, m := range msgs {
+		if m.Category != CategoryOther {
+			return messageError(m.ID, m.Description, m.Reason, reason, raw)
+		}
+	}
+	if text = strings.TrimSpace(text); reason != "" && text != "" {
+		return messageError("", text, "", reason, raw)
+	}
+	for _, m := range msgs {
+		if m.Severity == SeverityError {
+			return messageError(m.ID, m.Description, m.Reason, reason, raw)
+		}
+	}
+	if len(msgs) > 0 {
+		m := msgs[0]
+		return messageError(m.ID, m.Description, m.Reason, reason, raw)
+	}
+	return &APIError{StatusCode: http.StatusOK, ChangeStatus: reason, Body: raw, Err: ErrUnknown}
+}
@@ -0,0 +1,304 @@
+package ccw
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// Service identifies one of the CCW services provided by the client.
+type Service string
+
+// Services
+const (
+	ServiceEstimate Service = "estimate"
+	ServiceQuote    Service = "quote"
+)
+
+// Default base URLs for each service.
+const (
+	DefaultEstimateBaseURL = "https://api.cisco.com/commerce/EST/v2/async"
+	DefaultQuoteBaseURL    = "https://api.cisco.com/commerce/QUOTING/v1"
+)
+
+// Option configures a Client created with New.
+type Option func(*options) error
+
+type options struct {
+	httpClient     *http.Client
+	timeout        time.Duration
+	username       string
+	password       string
+	clientID       string
+	clientSecret   string
+	tokenSource    TokenSource
+	tokenURL       string
+	baseURLs       map[Service]string
+	limiter        *rate.Limiter
+	limiters       map[Service]*rate.Limiter
+	logger         Logger
+	userAgent      string
+	retryPolicy    *RetryPolicy
+	trace          TraceFunc
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// WithHTTPClient sets the HTTP client used to make requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) error {
+		o.httpClient = client
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of the default HTTP client, which is 10 seconds if not set.  It has
+// no effect if WithHTTPClient is used.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) error {
+		o.timeout = d
+		return nil
+	}
+}
+
+// WithCredentials authenticates using the OAuth2 password grant with the given credentials.
+func WithCredentials(username, password, clientID, clientSecret string) Option {
+	return func(o *options) error {
+		if username == "" || password == "" || clientID == "" || clientSecret == "" {
+			return errors.New("missing required parameters")
+		}
+		o.username, o.password, o.clientID, o.clientSecret = username, password, clientID, clientSecret
+		return nil
+	}
+}
+
+// WithClientCredentials authenticates using the OAuth2 client credentials grant.
+func WithClientCredentials(clientID, clientSecret string) Option {
+	return func(o *options) error {
+		if clientID == "" || clientSecret == "" {
+			return errors.New("missing required parameters")
+		}
+		o.clientID, o.clientSecret = clientID, clientSecret
+		return nil
+	}
+}
+
+// WithTokenSource authenticates using tokens from the given TokenSource.
+func WithTokenSource(ts TokenSource) Option {
+	return func(o *options) error {
+		if ts == nil {
+			return errors.New("missing token source")
+		}
+		o.tokenSource = ts
+		return nil
+	}
+}
+
+// WithTokenURL sets the token endpoint used with WithCredentials or WithClientCredentials,
+// instead of DefaultTokenURL.
+func WithTokenURL(u string) Option {
+	return func(o *options) error {
+		o.tokenURL = u
+		return nil
+	}
+}
+
+// WithBaseURL sets the base URL of the given service.
+func WithBaseURL(service Service, u string) Option {
+	return func(o *options) error {
+		switch service {
+		case ServiceEstimate, ServiceQuote:
+		default:
+			return fmt.Errorf("unknown service %q", service)
+		}
+		if o.baseURLs == nil {
+			o.baseURLs = make(map[Service]string)
+		}
+		o.baseURLs[service] = u
+		return nil
+	}
+}
+
+// WithRateLimit sets the maximum number of requests per second, and the burst size, shared by
+// all services that don't have their own rate limit.
+func WithRateLimit(r rate.Limit, burst int) Option {
+	return WithLimiter(rate.NewLimiter(r, burst))
+}
+
+// WithLimiter sets the limiter shared by all services that don't have their own rate limit.  The
+// limiter may be shared with other clients that use the same API credentials.
+func WithLimiter(lim *rate.Limiter) Option {
+	return func(o *options) error {
+		o.limiter = lim
+		return nil
+	}
+}
+
+// WithServiceRateLimit gives the given service its own rate limit.
+func WithServiceRateLimit(service Service, r rate.Limit, burst int) Option {
+	return WithServiceLimiter(service, rate.NewLimiter(r, burst))
+}
+
+// WithServiceLimiter gives the given service its own limiter, which may be shared with other clients.
+func WithServiceLimiter(service Service, lim *rate.Limiter) Option {
+	return func(o *options) error {
+		switch service {
+		case ServiceEstimate, ServiceQuote:
+		default:
+			return fmt.Errorf("unknown service %q", service)
+		}
+		if o.limiters == nil {
+			o.limiters = make(map[Service]*rate.Limiter)
+		}
+		o.limiters[service] = lim
+		return nil
+	}
+}
+
+// WithLogger sets the logger used by the client, which logs requests, responses and token
+// refreshes at debug level.  A *slog.Logger may be used.
+func WithLogger(l Logger) Option {
+	return func(o *options) error {
+		o.logger = l
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with requests to CCW.
+func WithUserAgent(ua string) Option {
+	return func(o *options) error {
+		o.userAgent = ua
+		return nil
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed requests, instead of DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *options) error {
+		o.retryPolicy = &p
+		return nil
+	}
+}
+
+// WithTrace passes every request made by the client, including token requests, and its response
+// to fn.  Use TraceWriter or TraceDir to capture the SOAP envelopes exchanged with CCW.
+// Authorization headers, passwords, client secrets and tokens are redacted.  Requests made by a
+// TokenSource given to WithTokenSource are not traced unless it uses the client's HTTPClient or
+// NewTraceTransport.
+func WithTrace(fn TraceFunc) Option {
+	return func(o *options) error {
+		o.trace = fn
+		return nil
+	}
+}
+
+// WithTracerProvider enables OpenTelemetry tracing.  A span is created for each operation, such
+// as acquiring a quote, with child spans for each HTTP request and token refresh.  Spans include
+// the operation name, deal ID, HTTP status and any CCW message IDs.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) error {
+		o.tracerProvider = tp
+		return nil
+	}
+}
+
+// WithMeterProvider enables OpenTelemetry metrics, recording the number of requests, errors,
+// retries and token refreshes along with request durations and the time spent waiting for the
+// rate limiter.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(o *options) error {
+		o.meterProvider = mp
+		return nil
+	}
+}
+
+// New returns a new ccw client configured with the given options.  One of WithCredentials,
+// WithClientCredentials or WithTokenSource is required.
+func New(opts ...Option) (*Client, error) {
+	var o options
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	client := o.httpClient
+	if client == nil {
+		timeout := o.timeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		client = &http.Client{
+			Timeout: timeout,
+		}
+	}
+	if o.trace != nil {
+		// copy the client so that tracing doesn't affect other users of it
+		traced := *client
+		traced.Transport = NewTraceTransport(client.Transport, o.trace)
+		client = &traced
+	}
+
+	ts := o.tokenSource
+	switch {
+	case ts != nil:
+	case o.username != "":
+		ts = &PasswordTokenSource{
+			TokenURL:     o.tokenURL,
+			Username:     o.username,
+			Password:     o.password,
+			ClientID:     o.clientID,
+			ClientSecret: o.clientSecret,
+			HTTPClient:   client,
+		}
+	case o.clientID != "":
+		ts = &ClientCredentialsTokenSource{
+			TokenURL:     o.tokenURL,
+			ClientID:     o.clientID,
+			ClientSecret: o.clientSecret,
+			HTTPClient:   client,
+		}
+	default:
+		return nil, errors.New("missing credentials or token source")
+	}
+
+	tel, err := newTelemetry(o.tracerProvider, o.meterProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		HTTPClient:  client,
+		RetryPolicy: DefaultRetryPolicy,
+		tokenSource: ts,
+		lim:         o.limiter,
+		logger:      o.logger,
+		userAgent:   o.userAgent,
+		telemetry:   tel,
+	}
+	if o.retryPolicy != nil {
+		c.RetryPolicy = *o.retryPolicy
+	}
+	if c.lim == nil {
+		c.lim = rate.NewLimiter(DefaultRateLimit, DefaultRateBurst)
+	}
+	if c.logger == nil {
+		c.logger = nopLogger{}
+	}
+
+	c.EstimateService = &EstimateService{client: c, BaseURL: DefaultEstimateBaseURL, lim: o.limiters[ServiceEstimate]}
+	c.QuoteService = &QuoteService{client: c, BaseURL: DefaultQuoteBaseURL, lim: o.limiters[ServiceQuote]}
+	if u, ok := o.baseURLs[ServiceEstimate]; ok {
+		c.EstimateService.BaseURL = u
+	}
+	if u, ok := o.baseURLs[ServiceQuote]; ok {
+		c.QuoteService.BaseURL = u
+	}
+
+	return c, nil
+}
@@ -0,0 +1,55 @@
+package ccw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_New(t *testing.T) {
+	if _, err := New(); err == nil {
+		t.Error("expected an error without credentials")
+	}
+	if _, err := New(WithCredentials("user", "", "id", "secret")); err == nil {
+		t.Error("expected an error with missing credentials")
+	}
+	if _, err := New(WithCredentials("user", "pass", "id", "secret"), WithBaseURL("unknown", "http://localhost")); err == nil {
+		t.Error("expected an error with an unknown service")
+	}
+
+	var userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Write([]byte(`{"access_token":"abc","token_type":"Bearer","expires_in":3599}`))
+			return
+		}
+		userAgent = r.Header.Get("User-Agent")
+		w.Write([]byte(`<Envelope/>`))
+	}))
+	defer srv.Close()
+
+	c, err := New(
+		WithCredentials("user", "pass", "id", "secret"),
+		WithTokenURL(srv.URL+"/token"),
+		WithBaseURL(ServiceQuote, srv.URL+"/quoting"),
+		WithUserAgent("ccw-test"),
+		WithRetryPolicy(NoRetryPolicy),
+		WithServiceRateLimit(ServiceEstimate, 1, 1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.QuoteService.BaseURL != srv.URL+"/quoting" || c.EstimateService.BaseURL != DefaultEstimateBaseURL {
+		t.Errorf("unexpected base urls %q, %q", c.QuoteService.BaseURL, c.EstimateService.BaseURL)
+	}
+	if c.RetryPolicy != NoRetryPolicy || c.EstimateService.lim == nil || c.QuoteService.lim != nil {
+		t.Errorf("options not applied to client")
+	}
+	if _, err := c.makeXMLRequest(context.Background(), nil, c.QuoteService.BaseURL, nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if userAgent != "ccw-test" {
+		t.Errorf("expected user agent to be sent, got %q", userAgent)
+	}
+}
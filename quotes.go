@@ -7,11 +7,10 @@ import (
 	"encoding/xml"
 	"fmt"
 	"math"
-	"net/http"
 	"reflect"
 	"strconv"
 	"strings"
-	"text/template"
+	"time"
 )
 
 type ListQuoteRequest struct {
@@ -22,16 +21,47 @@ type AcquireQuoteRequest struct {
 	DealID string
 }
 
+// ListQuoteResponseItem is a summary of a single quote returned by ListByDealID.
+type ListQuoteResponseItem struct {
+	DocumentID  string             `json:"documentId"`
+	QuoteName   string             `json:"quoteName"`
+	QuoteStatus string             `json:"quoteStatus"`
+	PriceList   string             `json:"priceList"`
+	DealID      string             `json:"dealId"`
+	Customer    Company            `json:"customer"`
+	ExpiryDate  string             `json:"expiryDate"`
+	Amounts     map[string]float64 `json:"amounts,omitempty"`
+	// Messages holds any warnings returned with the quote.
+	Messages []ConfigurationMessage `json:"messages,omitempty"`
+}
+
 type AcquireQuoteResponse struct {
-	QuoteName   string                     `json:"quoteName"`
-	QuoteOwner  string                     `json:"quoteOwner"`
-	QuoteStatus string                     `json:"quoteStatus"`
-	PriceList   string                     `json:"priceList"`
-	PriceListID string                     `json:"priceListId"`
-	DealID      string                     `json:"dealId"`
-	Customer    Company                    `json:"customer"`
-	Partner     Company                    `json:"partner"`
-	LineItems   []AcquireQuoteResponseItem `json:"items"`
+	DocumentID               string                     `json:"documentId"`
+	QuoteName                string                     `json:"quoteName"`
+	QuoteOwner               string                     `json:"quoteOwner"`
+	QuoteStatus              string                     `json:"quoteStatus"`
+	PriceList                string                     `json:"priceList"`
+	PriceListID              string                     `json:"priceListId"`
+	DealID                   string                     `json:"dealId"`
+	IntendedUseCode          string                     `json:"intendedUseCode"`
+	DocumentDateTime         *time.Time                 `json:"documentDateTime,omitempty"`
+	LastModificationDateTime *time.Time                 `json:"lastModificationDateTime,omitempty"`
+	ExpiryDate               *time.Time                 `json:"expiryDate,omitempty"`
+	NetPriceProtectionDate   *time.Time                 `json:"netPriceProtectionDate,omitempty"`
+	Customer                 Company                    `json:"customer"`
+	Partner                  Company                    `json:"partner"`
+	BillTo                   BillToParty                `json:"billTo"`
+	LineItems                []AcquireQuoteResponseItem `json:"items"`
+	Summary                  QuoteSummary               `json:"summary"`
+	AllConfigurationsValid   bool                       `json:"allConfigurationsValid"` // every line's configuration is verified
+	Messages                 []ConfigurationMessage     `json:"messages,omitempty"`
+}
+
+// BillToParty identifies the address a quote is billed to.
+type BillToParty struct {
+	AddressID       string `json:"addressId"`
+	Description     string `json:"description"`
+	DescriptionType string `json:"descriptionType"`
 }
 
 type Company struct {
@@ -71,6 +101,7 @@ type AcquireQuoteResponseItem struct {
 	UnitPrice                 float64     `json:"unitPrice"`
 	ExtendedAmount            float64     `json:"extendedAmount"`
 	TotalAmount               float64     `json:"totalAmount"`
+	Allowances                []Allowance `json:"allowances,omitempty"`
 	TotalDiscount             float64     `json:"totalDiscount"`
 	StandardDiscount          float64     `json:"standardDiscount"`
 	PromotionalDiscount       float64     `json:"promotionalDiscount"`
@@ -100,6 +131,56 @@ type AcquireQuoteResponseItem struct {
 
 	RemainingTerm           *float64 `json:"remainingTerm,omitempty"`
 	SubscriptionReferenceID *string  `json:"subscriptionReferenceID,omitempty"`
+
+	// Subscription change fields, present on lines that change an existing subscription, such
+	// as renewals and co-terms.  Unlike the fields above, zero values are kept since they
+	// indicate that the amount is unchanged.
+	CurrentBillingAmount    *float64 `json:"currentBillingAmount,omitempty"`
+	NewBillingAmount        *float64 `json:"newBillingAmount,omitempty"`
+	BillingAmountNetChange  *float64 `json:"billingAmountNetChange,omitempty"`
+	CurrentContractAmount   *float64 `json:"currentContractAmount,omitempty"`
+	NewContractAmount       *float64 `json:"newContractAmount,omitempty"`
+	ContractAmountNetChange *float64 `json:"contractAmountNetChange,omitempty"`
+	OldQuantity             *int64   `json:"oldQuantity,omitempty"`
+	UnitNetPriceWithCredits *float64 `json:"unitNetPriceWithCredits,omitempty"`
+
+	// Configuration fields
+	BuyMethod               *string                  `json:"buyMethod,omitempty"`
+	ListPriceVersion        *string                  `json:"listPriceVersion,omitempty"`
+	TransactionInfoID       *string                  `json:"transactionInfoId,omitempty"`
+	ConfigurationReferences []ConfigurationReference `json:"configurationReferences,omitempty"`
+	Configurator            *ConfiguratorInformation `json:"configurator,omitempty"`
+}
+
+// ConfigurationReference is the result of CCW verifying the configuration of a quote line.
+type ConfigurationReference struct {
+	Status   string `json:"status"`
+	Verified bool   `json:"verified"`
+}
+
+// ConfiguratorInformation identifies the configuration of a configurable quote line.
+type ConfiguratorInformation struct {
+	ConfigurationPath             string `json:"configurationPath"`
+	ProductConfigurationReference string `json:"productConfigurationReference"`
+	ConfigurationSelectCode       string `json:"configurationSelectCode"`
+}
+
+// ConfigurationValid reports whether every configuration reference of the line has been
+// verified by CCW.  Lines without configuration references are considered valid.
+func (item *AcquireQuoteResponseItem) ConfigurationValid() bool {
+	for _, ref := range item.ConfigurationReferences {
+		if !ref.Verified {
+			return false
+		}
+	}
+	return true
+}
+
+// Allowance is a credit or adjustment applied to a quote line.
+type Allowance struct {
+	Type     string  `json:"type"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
 }
 
 type floatOrNull float64
@@ -117,9 +198,47 @@ func (f floatOrNull) MarshalJSON() ([]byte, error) {
 	return []byte(strconv.FormatFloat(float64(f), 'f', -1, 64)), nil
 }
 
-func (s *QuoteService) AcquireByDealID(ctx context.Context, dealID string) (*AcquireQuoteResponse, error) {
+// dateTimeLayouts are the formats CCW uses for dates and times, which vary between fields.
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	"01/02/2006",
+	"02-Jan-2006",
+}
+
+// parseDateTime parses a date or time returned by CCW, returning nil if it is empty or in an
+// unrecognised format, so that a missing date can't be mistaken for a real one.  Values without
+// a time zone are treated as UTC.
+func parseDateTime(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+func (s *QuoteService) AcquireByDealID(ctx context.Context, dealID string) (aqr *AcquireQuoteResponse, err error) {
+	ctx, op := s.client.startOperation(ctx, "AcquireQuote", "deal_id", dealID)
+	defer func() {
+		var msgs []ConfigurationMessage
+		if aqr != nil {
+			msgs = aqr.Messages
+		}
+		op.end(err, msgs)
+	}()
+
+	if err := validateDealID(dealID); err != nil {
+		return nil, err
+	}
+
 	// 1. Load the template
-	template, err := template.ParseFS(templates, "templates/AcquireQuote_Request.xml")
+	template, err := lookupTemplate("AcquireQuote_Request.xml")
 	if err != nil {
 		return nil, err
 	}
@@ -134,40 +253,28 @@ func (s *QuoteService) AcquireByDealID(ctx context.Context, dealID string) (*Acq
 
 	// 4. Make the request
 	qurl := fmt.Sprintf("%s/AcquireQuoteService", s.BaseURL)
-	req, err := http.NewRequest("POST", qurl, strings.NewReader(tpl.String()))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Add("Accept", "application/xml")
-	req.Header.Add("Content-Type", "application/xml")
 
 	var resp AcquireQuoteXMLResponse
-	err = s.client.makeXMLRequest(ctx, req, &resp)
+	raw, err := s.client.makeXMLRequest(ctx, s.limiter(), qurl, tpl.Bytes(), &resp)
 	if err != nil {
 		return nil, err
 	}
 
-	quoteSuccess := resp.Body.ShowQuote.DataArea.Show.ResponseCriteria.ChangeStatus.Reason
-	if quoteSuccess != "Success" {
-		if resp.Body.ShowQuote.DataArea.Show.ResponseCriteria.ChangeStatus.Reason != "" && resp.Body.ShowQuote.DataArea.Show.ResponseCriteria.ChangeStatus.Text != "" {
-			return nil, fmt.Errorf("%s: %s", resp.Body.ShowQuote.DataArea.Show.ResponseCriteria.ChangeStatus.Reason, resp.Body.ShowQuote.DataArea.Show.ResponseCriteria.ChangeStatus.Text)
-		}
-		if resp.Body.ShowQuote.DataArea.Quote.QuoteHeader.UserArea.CiscoExtensions.CiscoHeader.ConfigurationMessages.ID != "" && resp.Body.ShowQuote.DataArea.Quote.QuoteHeader.UserArea.CiscoExtensions.CiscoHeader.ConfigurationMessages.Description != "" {
-			if resp.Body.ShowQuote.DataArea.Quote.QuoteHeader.UserArea.CiscoExtensions.CiscoHeader.ConfigurationMessages.ID == "DAQS033" {
-				return nil, fmt.Errorf("%w: %s: %s", ErrNotFound, resp.Body.ShowQuote.DataArea.Quote.QuoteHeader.UserArea.CiscoExtensions.CiscoHeader.ConfigurationMessages.ID, resp.Body.ShowQuote.DataArea.Quote.QuoteHeader.UserArea.CiscoExtensions.CiscoHeader.ConfigurationMessages.Description)
-			}
-			return nil, fmt.Errorf("%s: %s", resp.Body.ShowQuote.DataArea.Quote.QuoteHeader.UserArea.CiscoExtensions.CiscoHeader.ConfigurationMessages.ID, resp.Body.ShowQuote.DataArea.Quote.QuoteHeader.UserArea.CiscoExtensions.CiscoHeader.ConfigurationMessages.Description)
-		}
-		return nil, ErrUnknown
+	if err := resp.err(raw); err != nil {
+		return nil, err
 	}
 
 	// 5. Format the response
-	var aqr AcquireQuoteResponse
+	aqr = &AcquireQuoteResponse{}
 
 	// get header details
 	quoteHeader := resp.Body.ShowQuote.DataArea.Quote.QuoteHeader
 
+	aqr.DocumentID = quoteHeader.DocumentID.ID
+	aqr.DocumentDateTime = parseDateTime(quoteHeader.DocumentDateTime)
+	aqr.LastModificationDateTime = parseDateTime(quoteHeader.LastModificationDateTime)
+	aqr.ExpiryDate = parseDateTime(quoteHeader.EffectiveTimePeriod.EndDateTime)
+
 	if quoteHeader.Extension.ValueText.TypeCode == "QuoteName" {
 		aqr.QuoteName = quoteHeader.Extension.ValueText.Text
 	}
@@ -242,16 +349,61 @@ func (s *QuoteService) AcquireByDealID(ctx context.Context, dealID string) (*Acq
 		aqr.DealID = quoteHeader.QualificationTerm.ID.Text
 	}
 
-	for _, p := range quoteHeader.UserArea.CiscoExtensions.CiscoHeader.PriceList {
+	aqr.BillTo = BillToParty{
+		AddressID:       quoteHeader.BillToParty.Location.Address.ID,
+		Description:     quoteHeader.BillToParty.Location.Description.Text,
+		DescriptionType: quoteHeader.BillToParty.Location.Description.Type,
+	}
+
+	ciscoHeader := quoteHeader.UserArea.CiscoExtensions.CiscoHeader
+	for _, p := range ciscoHeader.PriceList {
 		if p.Description != "" && p.ID != "" {
 			aqr.PriceList = p.Description
 			aqr.PriceListID = p.ID
 		}
 	}
+	aqr.IntendedUseCode = ciscoHeader.IntendedUseCode
+	aqr.NetPriceProtectionDate = parseDateTime(ciscoHeader.NetPriceProtectionDate)
 
 	// Now get the quote lines
+	aqr.LineItems = resp.lineItems()
+	aqr.Summary = summarizeLineItems(aqr.LineItems)
+	aqr.AllConfigurationsValid = true
+	for i := range aqr.LineItems {
+		if !aqr.LineItems[i].ConfigurationValid() {
+			aqr.AllConfigurationsValid = false
+		}
+	}
+
+	// Include any warnings returned alongside the quote
+	aqr.Messages = resp.messages()
+
+	return aqr, nil
+}
+
+// err checks the response status and configuration messages, returning an *APIError if the
+// request was not successful.
+func (r *AcquireQuoteXMLResponse) err(raw []byte) error {
+	changeStatus := r.Body.ShowQuote.DataArea.Show.ResponseCriteria.ChangeStatus
+	return responseError(changeStatus.Reason, changeStatus.Text, r.messages(), raw)
+}
 
-	quoteLines := resp.Body.ShowQuote.DataArea.Quote.QuoteLine
+// messages returns the configuration messages in the quote header, classified by ID or description.
+func (r *AcquireQuoteXMLResponse) messages() []ConfigurationMessage {
+	success := r.Body.ShowQuote.DataArea.Show.ResponseCriteria.ChangeStatus.Reason == "Success"
+	var msgs []ConfigurationMessage
+	for _, m := range r.Body.ShowQuote.DataArea.Quote.QuoteHeader.UserArea.CiscoExtensions.CiscoHeader.ConfigurationMessages {
+		if m.ID != "" && m.Description != "" {
+			msgs = append(msgs, newConfigurationMessage("", m.ID, m.Description, m.Reason, success))
+		}
+	}
+	return msgs
+}
+
+// lineItems converts the quote lines in the response into AcquireQuoteResponseItems.
+func (r *AcquireQuoteXMLResponse) lineItems() []AcquireQuoteResponseItem {
+	var items []AcquireQuoteResponseItem
+	quoteLines := r.Body.ShowQuote.DataArea.Quote.QuoteLine
 
 	for _, line := range quoteLines {
 		ql := AcquireQuoteResponseItem{}
@@ -336,6 +488,21 @@ func (s *QuoteService) AcquireByDealID(ctx context.Context, dealID string) (*Acq
 		ql.UnitPrice = vUnitPrice
 		vQuantity, _ := strconv.ParseInt(line.Quantity, 10, 0)
 		ql.Quantity = vQuantity
+		vExtendedAmount, _ := strconv.ParseFloat(line.ExtendedAmount.Text, 64)
+		ql.ExtendedAmount = vExtendedAmount
+		vTotalAmount, _ := strconv.ParseFloat(line.TotalAmount.Text, 64)
+		ql.TotalAmount = vTotalAmount
+		for _, allowance := range line.Allowance {
+			if allowance.Type.Text == "" && allowance.Amount.Text == "" {
+				continue
+			}
+			v, _ := strconv.ParseFloat(allowance.Amount.Text, 64)
+			ql.Allowances = append(ql.Allowances, Allowance{
+				Type:     allowance.Type.Text,
+				Amount:   v,
+				Currency: allowance.Amount.CurrencyID,
+			})
+		}
 
 		// Additional UserArea fields
 		ciscoLine := line.UserArea.CiscoExtensions.CiscoLine
@@ -354,11 +521,134 @@ func (s *QuoteService) AcquireByDealID(ctx context.Context, dealID string) (*Acq
 		ql.PricingTerm = IntOrNil(pricingTerm)
 		remainingTerm, _ := strconv.ParseFloat(ciscoLine.RemainingTerm, 64)
 		ql.RemainingTerm = FloatOrNil(remainingTerm)
+		ql.CurrentBillingAmount = parseFloatOrNil(ciscoLine.CurrentBillingAmount)
+		ql.NewBillingAmount = parseFloatOrNil(ciscoLine.NewBillingAmount)
+		ql.BillingAmountNetChange = parseFloatOrNil(ciscoLine.BillingAmountNetChange)
+		ql.CurrentContractAmount = parseFloatOrNil(ciscoLine.CurrentContractAmount)
+		ql.NewContractAmount = parseFloatOrNil(ciscoLine.NewContractAmount)
+		ql.ContractAmountNetChange = parseFloatOrNil(ciscoLine.ContractAmountNetChange)
+		ql.OldQuantity = parseIntOrNil(ciscoLine.OldQuantity)
+		ql.UnitNetPriceWithCredits = parseFloatOrNil(ciscoLine.UnitNetPriceWithCredits)
+
+		// Configuration details
+		ql.BuyMethod = String(ciscoLine.BuyMethod)
+		ql.ListPriceVersion = String(ciscoLine.ListPriceVersion)
+		ql.TransactionInfoID = String(ciscoLine.TransactionInfoID)
+		for _, ref := range ciscoLine.ConfigurationReference {
+			if ref.Status.Reason == "" && ref.VerifiedConfigurationIndicator == "" {
+				continue
+			}
+			ql.ConfigurationReferences = append(ql.ConfigurationReferences, ConfigurationReference{
+				Status:   ref.Status.Reason,
+				Verified: parseIndicator(ref.VerifiedConfigurationIndicator),
+			})
+		}
+		if info := ciscoLine.ConfiguratorInformation; info.ConfigurationPath != "" || info.ProductConfigurationReference != "" || info.ConfigurationSelectCode != "" {
+			ql.Configurator = &ConfiguratorInformation{
+				ConfigurationPath:             info.ConfigurationPath,
+				ProductConfigurationReference: info.ProductConfigurationReference,
+				ConfigurationSelectCode:       info.ConfigurationSelectCode,
+			}
+		}
+
+		items = append(items, ql)
+	}
+
+	return items
+}
+
+// ListByDealID returns a summary of each of the quotes associated with the given deal ID.
+func (s *QuoteService) ListByDealID(ctx context.Context, dealID string) (quotes []ListQuoteResponseItem, err error) {
+	ctx, op := s.client.startOperation(ctx, "ListQuote", "deal_id", dealID)
+	defer func() {
+		var msgs []ConfigurationMessage
+		for _, q := range quotes {
+			msgs = append(msgs, q.Messages...)
+		}
+		op.end(err, msgs)
+	}()
+
+	if err := validateDealID(dealID); err != nil {
+		return nil, err
+	}
+
+	// 1. Load the template
+	template, err := lookupTemplate("ListQuote_Request.xml")
+	if err != nil {
+		return nil, err
+	}
+	// 2. Create the data for the template
+	data := ListQuoteRequest{DealID: dealID}
+
+	// 3. Apply the data to the template
+	var tpl bytes.Buffer
+	if err := template.Execute(&tpl, data); err != nil {
+		return nil, err
+	}
+
+	// 4. Make the request
+	qurl := fmt.Sprintf("%s/ListQuoteService", s.BaseURL)
+
+	var resp ListQuoteXMLResponse
+	raw, err := s.client.makeXMLRequest(ctx, s.limiter(), qurl, tpl.Bytes(), &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	// 5. Format the response
+	quotes = []ListQuoteResponseItem{}
+	for _, quote := range resp.Body.ShowQuote.DataArea.Quote {
+		quoteHeader := quote.QuoteHeader
+		// messages returned with a quote are warnings, otherwise they explain why there isn't one
+		found := quoteHeader.DocumentID.ID != ""
+		var msgs []ConfigurationMessage
+		for _, m := range quoteHeader.UserArea.CiscoExtensions.CiscoHeader.ConfigurationMessages {
+			if m.ID != "" && m.Description != "" {
+				msgs = append(msgs, newConfigurationMessage("", m.ID, m.Description, m.Reason, found))
+			}
+		}
+		if !found && len(msgs) > 0 {
+			return nil, responseError("", "", msgs, raw)
+		}
 
-		aqr.LineItems = append(aqr.LineItems, ql)
+		lq := ListQuoteResponseItem{
+			DocumentID:  quoteHeader.DocumentID.ID,
+			QuoteName:   quoteHeader.Description.Text,
+			QuoteStatus: quoteHeader.Status.Code.Text,
+			PriceList:   quoteHeader.UserArea.CiscoExtensions.CiscoHeader.PriceList.Description,
+			DealID:      quoteHeader.QualificationTerm.ID.Text,
+			Customer: Company{
+				Name: quoteHeader.Party.Name,
+				Location: Address{
+					LineOne:                quoteHeader.Party.Location.Address.AddressLine.Text,
+					CityName:               quoteHeader.Party.Location.Address.CityName,
+					CountrySubDivisionCode: quoteHeader.Party.Location.Address.CountrySubDivisionCode,
+					CountryCode:            quoteHeader.Party.Location.Address.CountryCode,
+					PostalCode:             quoteHeader.Party.Location.Address.PostalCode,
+				},
+			},
+			ExpiryDate: quoteHeader.EffectiveTimePeriod.EndDateTime,
+			Messages:   msgs,
+		}
+		for _, t := range quoteHeader.Extension.Text {
+			if t.TypeCode == "QuoteName" && t.Text != "" {
+				lq.QuoteName = t.Text
+			}
+		}
+		for _, amount := range quoteHeader.Extension.Amount {
+			if amount.TypeCode == "" {
+				continue
+			}
+			if lq.Amounts == nil {
+				lq.Amounts = make(map[string]float64)
+			}
+			v, _ := strconv.ParseFloat(amount.Text, 64)
+			lq.Amounts[amount.TypeCode] = v
+		}
+		quotes = append(quotes, lq)
 	}
 
-	return &aqr, nil
+	return quotes, nil
 }
 
 type AcquireQuoteXMLResponse struct {
@@ -572,7 +862,7 @@ type AcquireQuoteXMLResponse struct {
 										Description string `xml:"Description"`
 										ShortName   string `xml:"ShortName"`
 									} `xml:"PriceList"`
-									ConfigurationMessages struct {
+									ConfigurationMessages []struct {
 										Text        string `xml:",chardata"`
 										ID          string `xml:"ID"`
 										Description string `xml:"Description"`
@@ -910,7 +1200,7 @@ type ListQuoteXMLResponse struct {
 										Text        string `xml:",chardata"`
 										Description string `xml:"Description"`
 									} `xml:"PriceList"`
-									ConfigurationMessages struct {
+									ConfigurationMessages []struct {
 										Text        string `xml:",chardata"`
 										ID          string `xml:"ID"`
 										Description string `xml:"Description"`
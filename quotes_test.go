@@ -0,0 +1,305 @@
+package ccw
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const acquireQuoteXML = `<Envelope><Body><ShowQuote><DataArea>
+<Show><ResponseCriteria><ChangeStatus><Reason>Success</Reason></ChangeStatus></ResponseCriteria></Show>
+<Quote>
+	<QuoteLine>
+		<LineNumber>1.0</LineNumber>
+		<Item><ItemID><ID>C9300-24T-E</ID></ItemID></Item>
+		<Quantity>2</Quantity>
+		<UnitPrice><Amount currencyID="USD">4900.00</Amount></UnitPrice>
+		<ExtendedAmount currencyID="USD">9800.00</ExtendedAmount>
+		<TotalAmount currencyID="USD">5780.00</TotalAmount>
+		<Allowance><Type>Credit</Type><Amount currencyID="USD">100.00</Amount></Allowance>
+		<Allowance><Type>TradeIn</Type><Amount currencyID="USD">20.50</Amount></Allowance>
+		<UserArea><CiscoExtensions><CiscoLine>
+			<BuyMethod>Standard</BuyMethod>
+			<ConfigurationReference>
+				<Status><Reason>Valid</Reason></Status>
+				<VerifiedConfigurationIndicator>Y</VerifiedConfigurationIndicator>
+			</ConfigurationReference>
+			<ConfiguratorInformation>
+				<ConfigurationPath>C9300-24T-E</ConfigurationPath>
+				<ProductConfigurationReference>CFG-1001</ProductConfigurationReference>
+				<ConfigurationSelectCode>Y</ConfigurationSelectCode>
+			</ConfiguratorInformation>
+			<ListPriceVersion>1109-2023</ListPriceVersion>
+			<TransactionInfoID>TX-42</TransactionInfoID>
+		</CiscoLine></CiscoExtensions></UserArea>
+	</QuoteLine>
+	<QuoteLine>
+		<LineNumber>1.1</LineNumber>
+		<Item><ItemID><ID>CON-SNT-C93002TE</ID></ItemID></Item>
+		<Quantity>2</Quantity>
+		<UnitPrice><Amount currencyID="USD">612.00</Amount></UnitPrice>
+		<ExtendedAmount currencyID="USD">1224.00</ExtendedAmount>
+		<TotalAmount currencyID="USD">1101.60</TotalAmount>
+		<UserArea><CiscoExtensions><CiscoLine>
+			<ConfigurationReference>
+				<Status><Reason>Invalid</Reason></Status>
+				<VerifiedConfigurationIndicator>N</VerifiedConfigurationIndicator>
+			</ConfigurationReference>
+			<CurrentBillingAmount>1000.00</CurrentBillingAmount>
+			<NewBillingAmount>1101.60</NewBillingAmount>
+			<BillingAmountNetChange>101.60</BillingAmountNetChange>
+			<ContractAmountNetChange>0</ContractAmountNetChange>
+			<OldQuantity>1</OldQuantity>
+			<UnitNetPriceWithCredits>550.80</UnitNetPriceWithCredits>
+		</CiscoLine></CiscoExtensions></UserArea>
+	</QuoteLine>
+</Quote>
+</DataArea></ShowQuote></Body></Envelope>`
+
+func decodeAcquireQuote(t *testing.T) *AcquireQuoteXMLResponse {
+	t.Helper()
+	var resp AcquireQuoteXMLResponse
+	if err := xml.Unmarshal([]byte(acquireQuoteXML), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return &resp
+}
+
+func Test_LineItemAmounts(t *testing.T) {
+	items := decodeAcquireQuote(t).lineItems()
+	if len(items) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(items))
+	}
+
+	tests := []struct {
+		line           int
+		extendedAmount float64
+		totalAmount    float64
+		allowances     []Allowance
+	}{
+		{line: 0, extendedAmount: 9800, totalAmount: 5780, allowances: []Allowance{{Type: "Credit", Amount: 100, Currency: "USD"}, {Type: "TradeIn", Amount: 20.5, Currency: "USD"}}},
+		{line: 1, extendedAmount: 1224, totalAmount: 1101.6},
+	}
+	for _, tc := range tests {
+		got := items[tc.line]
+		if got.ExtendedAmount != tc.extendedAmount || got.TotalAmount != tc.totalAmount {
+			t.Errorf("line %d: expected amounts %v/%v, got %v/%v", tc.line, tc.extendedAmount, tc.totalAmount, got.ExtendedAmount, got.TotalAmount)
+		}
+		if len(got.Allowances) != len(tc.allowances) {
+			t.Errorf("line %d: expected allowances %+v, got %+v", tc.line, tc.allowances, got.Allowances)
+			continue
+		}
+		for i := range tc.allowances {
+			if got.Allowances[i] != tc.allowances[i] {
+				t.Errorf("line %d: expected allowance %+v, got %+v", tc.line, tc.allowances[i], got.Allowances[i])
+			}
+		}
+	}
+}
+
+func Test_LineItemSubscriptionChange(t *testing.T) {
+	items := decodeAcquireQuote(t).lineItems()
+	if got := items[0]; got.hasSubscriptionChange() || got.OldQuantity != nil {
+		t.Errorf("expected no subscription change on line 0, got %+v", got)
+	}
+
+	got := items[1]
+	tests := []struct {
+		name string
+		got  *float64
+		want *float64
+	}{
+		{name: "current billing", got: got.CurrentBillingAmount, want: Float64(1000)},
+		{name: "new billing", got: got.NewBillingAmount, want: Float64(1101.6)},
+		{name: "billing net change", got: got.BillingAmountNetChange, want: Float64(101.6)},
+		{name: "current contract", got: got.CurrentContractAmount, want: nil},
+		{name: "contract net change", got: got.ContractAmountNetChange, want: Float64(0)},
+		{name: "unit net price with credits", got: got.UnitNetPriceWithCredits, want: Float64(550.8)},
+	}
+	for _, tc := range tests {
+		if (tc.got == nil) != (tc.want == nil) || (tc.got != nil && *tc.got != *tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, tc.got)
+		}
+	}
+	if got.OldQuantity == nil || *got.OldQuantity != 1 {
+		t.Errorf("expected old quantity 1, got %v", got.OldQuantity)
+	}
+}
+
+func Test_LineItemConfiguration(t *testing.T) {
+	items := decodeAcquireQuote(t).lineItems()
+
+	got := items[0]
+	if got.BuyMethod == nil || *got.BuyMethod != "Standard" || got.ListPriceVersion == nil || *got.ListPriceVersion != "1109-2023" || got.TransactionInfoID == nil || *got.TransactionInfoID != "TX-42" {
+		t.Errorf("unexpected configuration fields %+v", got)
+	}
+	want := ConfiguratorInformation{ConfigurationPath: "C9300-24T-E", ProductConfigurationReference: "CFG-1001", ConfigurationSelectCode: "Y"}
+	if got.Configurator == nil || *got.Configurator != want {
+		t.Errorf("expected configurator %+v, got %+v", want, got.Configurator)
+	}
+	if len(got.ConfigurationReferences) != 1 || got.ConfigurationReferences[0] != (ConfigurationReference{Status: "Valid", Verified: true}) || !got.ConfigurationValid() {
+		t.Errorf("expected a verified configuration, got %+v", got.ConfigurationReferences)
+	}
+
+	got = items[1]
+	if got.Configurator != nil || got.BuyMethod != nil {
+		t.Errorf("expected no configurator, got %+v", got.Configurator)
+	}
+	if len(got.ConfigurationReferences) != 1 || got.ConfigurationReferences[0] != (ConfigurationReference{Status: "Invalid"}) || got.ConfigurationValid() {
+		t.Errorf("expected an invalid configuration, got %+v", got.ConfigurationReferences)
+	}
+
+	if !(&AcquireQuoteResponseItem{}).ConfigurationValid() {
+		t.Error("expected a line without configuration references to be valid")
+	}
+}
+
+func Test_ParseDateTime(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{input: "", want: time.Time{}},
+		{input: "2023-03-01T09:30:00Z", want: time.Date(2023, 3, 1, 9, 30, 0, 0, time.UTC)},
+		{input: "2023-03-01T09:30:00.000-07:00", want: time.Date(2023, 3, 1, 16, 30, 0, 0, time.UTC)},
+		{input: "2023-03-01T09:30:00", want: time.Date(2023, 3, 1, 9, 30, 0, 0, time.UTC)},
+		{input: " 2023-06-30 ", want: time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)},
+		{input: "06/30/2023", want: time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)},
+		{input: "30-Jun-2023", want: time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)},
+		{input: "not a date", want: time.Time{}},
+	}
+	for _, tc := range tests {
+		got := parseDateTime(tc.input)
+		if tc.want.IsZero() {
+			if got != nil {
+				t.Errorf("parseDateTime(%q): expected nil, got %v", tc.input, got)
+			}
+			continue
+		}
+		if got == nil || !got.Equal(tc.want) {
+			t.Errorf("parseDateTime(%q): expected %v, got %v", tc.input, tc.want, got)
+		}
+	}
+}
+
+const listQuoteXML = `<Envelope><Body><ShowQuote><DataArea>
+<Quote>
+	<QuoteHeader>
+		<DocumentID><ID>4700000001</ID></DocumentID>
+		<Description>Fallback name</Description>
+		<Status><Code>APPROVED</Code></Status>
+		<Party role="End Customer">
+			<Name>Example Customer</Name>
+			<Location><Address>
+				<AddressLine sequence="1">1 Main Street</AddressLine>
+				<CityName>London</CityName>
+				<CountryCode>GB</CountryCode>
+				<PostalCode>EC1A 1AA</PostalCode>
+			</Address></Location>
+		</Party>
+		<QualificationTerm><ID>12345678</ID></QualificationTerm>
+		<UserArea><CiscoExtensions><CiscoHeader>
+			<PriceList><Description>Global Price List - US</Description></PriceList>
+		</CiscoHeader></CiscoExtensions></UserArea>
+		<Extension>
+			<Text typeCode="QuoteName">Branch &amp; Campus</Text>
+			<Amount typeCode="TotalListPrice">11024.00</Amount>
+			<Amount typeCode="TotalNetPrice">6981.60</Amount>
+			<Amount>1.00</Amount>
+		</Extension>
+		<EffectiveTimePeriod><EndDateTime>2023-06-30</EndDateTime></EffectiveTimePeriod>
+	</QuoteHeader>
+</Quote>
+<Quote>
+	<QuoteHeader>
+		<DocumentID><ID>4700000002</ID></DocumentID>
+		<Description>Second quote</Description>
+		<Status><Code>NOT_SUBMITTED</Code></Status>
+	</QuoteHeader>
+</Quote>
+</DataArea></ShowQuote></Body></Envelope>`
+
+func Test_ListByDealID(t *testing.T) {
+	var requests []string
+	c := newTestClient(t, listQuoteXML, &requests)
+	quotes, err := c.QuoteService.ListByDealID(context.Background(), "12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 || !strings.Contains(requests[0], "12345678") {
+		t.Errorf("expected a request for the deal ID, got %q", requests)
+	}
+
+	want := []ListQuoteResponseItem{
+		{
+			DocumentID:  "4700000001",
+			QuoteName:   "Branch & Campus",
+			QuoteStatus: "APPROVED",
+			PriceList:   "Global Price List - US",
+			DealID:      "12345678",
+			Customer: Company{
+				Name:     "Example Customer",
+				Location: Address{LineOne: "1 Main Street", CityName: "London", CountryCode: "GB", PostalCode: "EC1A 1AA"},
+			},
+			ExpiryDate: "2023-06-30",
+			Amounts:    map[string]float64{"TotalListPrice": 11024, "TotalNetPrice": 6981.6},
+		},
+		{DocumentID: "4700000002", QuoteName: "Second quote", QuoteStatus: "NOT_SUBMITTED"},
+	}
+	if !reflect.DeepEqual(quotes, want) {
+		t.Errorf("expected %+v, got %+v", want, quotes)
+	}
+
+	if _, err := c.QuoteService.ListByDealID(context.Background(), "ABC"); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("expected ErrBadRequest for an invalid deal ID, got %v", err)
+	}
+}
+
+func Test_ListByDealIDMessages(t *testing.T) {
+	tests := []struct {
+		id, description string
+		// other is an uncategorised message returned after the expected one
+		other bool
+		want  error
+	}{
+		{id: "DAQS033", description: "No quote found for the deal ID", want: ErrNotFound},
+		{id: "X1", description: "Something happened", want: ErrUnknown},
+		{id: "DAQS033", description: "No quote found for the deal ID", other: true, want: ErrNotFound},
+	}
+	for _, tc := range tests {
+		var other string
+		if tc.other {
+			other = `<ConfigurationMessages><ID>X2</ID><Description>Something else happened</Description></ConfigurationMessages>`
+		}
+		body := `<Envelope><Body><ShowQuote><DataArea><Quote><QuoteHeader>
+<UserArea><CiscoExtensions><CiscoHeader><ConfigurationMessages>
+	<ID>` + tc.id + `</ID><Description>` + tc.description + `</Description>
+</ConfigurationMessages>` + other + `</CiscoHeader></CiscoExtensions></UserArea>
+</QuoteHeader></Quote></DataArea></ShowQuote></Body></Envelope>`
+		c := newTestClient(t, body, nil)
+		_, err := c.QuoteService.ListByDealID(context.Background(), "12345678")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.MessageID != tc.id || apiErr.Description != tc.description {
+			t.Errorf("%s: expected an APIError for the message, got %v", tc.id, err)
+			continue
+		}
+		if apiErr.Err != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.id, tc.want, apiErr.Err)
+		}
+	}
+}
+
+func Test_AcquireQuoteResponseOmitsMissingDates(t *testing.T) {
+	b, err := json.Marshal(AcquireQuoteResponse{ExpiryDate: parseDateTime("2023-06-30"), NetPriceProtectionDate: parseDateTime("soon")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"expiryDate":"2023-06-30T00:00:00Z"`) || strings.Contains(string(b), "netPriceProtectionDate") || strings.Contains(string(b), "0001-01-01") {
+		t.Errorf("expected only the expiry date to be included, got %s", b)
+	}
+}
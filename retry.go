@@ -0,0 +1,136 @@
+package ccw
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests that fail with a transient error are retried.  Requests are
+// retried when CCW responds with 429 Too Many Requests or a 5xx status, or when the request
+// times out.  The delay between attempts grows exponentially from InitialBackoff up to
+// MaxBackoff, with random jitter applied, unless the response includes a Retry-After header in
+// which case that is honoured instead, up to MaxBackoff.
+//
+// Operations that change data in CCW, such as creating or updating an estimate, aren't
+// idempotent, since CCW may have made the change before a 5xx response or timeout.  They are
+// only retried after a 429 response, unless the context is marked using Idempotent.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.  A value of one or
+	// less disables retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between attempts.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by new clients.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// NoRetryPolicy disables retries.
+var NoRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// backoff returns how long to wait before the next attempt, given the number of attempts made
+// so far and any delay requested by the server.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if p.MaxBackoff > 0 && retryAfter > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+		return retryAfter
+	}
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// use "equal jitter" so we always wait at least half of the calculated delay
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// idempotentKey is the context key marking whether the operation is safe to retry.
+type idempotentKey struct{}
+
+// Idempotent returns a copy of ctx that marks operations made with it as safe to retry after a
+// 5xx response or timeout, even if they would otherwise change data in CCW.  Use this, for
+// example, when creating an estimate if duplicate estimates are detected or acceptable.
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// withoutRetries marks the operation as not idempotent, unless the caller has used Idempotent.
+func withoutRetries(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(idempotentKey{}).(bool); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, idempotentKey{}, false)
+}
+
+// isIdempotent reports whether the operation is safe to retry, which it is unless marked otherwise.
+func isIdempotent(ctx context.Context) bool {
+	idempotent, ok := ctx.Value(idempotentKey{}).(bool)
+	return idempotent || !ok
+}
+
+// retryable reports whether a request that failed with the given error may succeed if retried,
+// and how long the server asked us to wait before doing so.  Requests that aren't idempotent are
+// only retried if CCW rejected them with 429 Too Many Requests.
+func retryable(err error, idempotent bool) (bool, time.Duration) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Fault != nil && apiErr.Fault.isClient() {
+			// the request itself was rejected, so sending it again won't help
+			return false, 0
+		}
+		if !idempotent {
+			return apiErr.StatusCode == http.StatusTooManyRequests, apiErr.retryAfter
+		}
+		return isRetryableStatus(apiErr.StatusCode), apiErr.retryAfter
+	}
+	return idempotent && isTimeout(err), 0
+}
+
+// isRetryableStatus reports whether a request that failed with the given status may succeed if retried.
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// isTimeout reports whether the error is the result of a request timing out.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which may be either a number of
+// seconds or an HTTP date, returning zero if it is missing or invalid.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
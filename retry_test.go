@@ -0,0 +1,147 @@
+package ccw
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_RetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{attempt: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{attempt: 2, min: 100 * time.Millisecond, max: 200 * time.Millisecond},
+		{attempt: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{attempt: 10, min: 500 * time.Millisecond, max: time.Second},
+		{attempt: 1, retryAfter: 300 * time.Millisecond, min: 300 * time.Millisecond, max: 300 * time.Millisecond},
+		{attempt: 1, retryAfter: time.Hour, min: time.Second, max: time.Second},
+	}
+
+	for _, tc := range tests {
+		got := p.backoff(tc.attempt, tc.retryAfter)
+		if got < tc.min || got > tc.max {
+			t.Errorf("attempt %d: expected backoff between %v and %v, got %v", tc.attempt, tc.min, tc.max, got)
+		}
+	}
+}
+
+func Test_ParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("120"); got != 2*time.Minute {
+		t.Errorf("expected 2m, got %v", got)
+	}
+	if got := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); got <= 0 || got > time.Minute {
+		t.Errorf("expected up to 1m, got %v", got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("expected 0, got %v", got)
+	}
+}
+
+func Test_MakeXMLRequestRetries(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`<Envelope><Body>ok</Body></Envelope>`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	var v struct {
+		Body string `xml:"Body"`
+	}
+	if _, err := c.makeXMLRequest(context.Background(), nil, srv.URL, []byte("<x/>"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || v.Body != "ok" {
+		t.Errorf("expected 3 calls and a decoded body, got %d calls and %q", calls, v.Body)
+	}
+
+	calls = 0
+	c.RetryPolicy = NoRetryPolicy
+	_, err = c.makeXMLRequest(context.Background(), nil, srv.URL, []byte("<x/>"), &v)
+	if !errors.Is(err, ErrServiceUnavailable) || calls != 1 {
+		t.Errorf("expected a single attempt failing with ErrServiceUnavailable, got %v after %d calls", err, calls)
+	}
+}
+
+func Test_MakeXMLRequestRetriesOnlyIdempotentRequests(t *testing.T) {
+	var calls int
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		status int
+		want   int
+	}{
+		{name: "read", ctx: context.Background(), status: http.StatusServiceUnavailable, want: 3},
+		{name: "write", ctx: withoutRetries(context.Background()), status: http.StatusServiceUnavailable, want: 1},
+		{name: "write rejected by rate limit", ctx: withoutRetries(context.Background()), status: http.StatusTooManyRequests, want: 3},
+		{name: "write marked idempotent", ctx: withoutRetries(Idempotent(context.Background())), status: http.StatusServiceUnavailable, want: 3},
+	}
+	for _, tc := range tests {
+		calls = 0
+		status = tc.status
+		if _, err := c.makeXMLRequest(tc.ctx, nil, srv.URL, []byte("<x/>"), &struct{}{}); err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+		if calls != tc.want {
+			t.Errorf("%s: expected %d calls, got %d", tc.name, tc.want, calls)
+		}
+	}
+}
+
+func Test_MakeXMLRequestRetryAfterBeyondDeadline(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = c.makeXMLRequest(ctx, nil, srv.URL, []byte("<x/>"), &struct{}{})
+	if !errors.Is(err, ErrServiceUnavailable) || calls != 1 {
+		t.Errorf("expected a single attempt failing with ErrServiceUnavailable, got %v after %d calls", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected to fail without waiting, took %v", elapsed)
+	}
+}
@@ -0,0 +1,165 @@
+package ccw
+
+import (
+	"math"
+	"strings"
+)
+
+// QuoteSummary contains totals calculated from the lines of a quote.  List amounts are the
+// extended list price of each line and net amounts are the total amount after discounts.
+// Amounts are in the currency of the quote.
+type QuoteSummary struct {
+	Currency string `json:"currency"`
+	Totals
+	// EffectiveDiscount is the overall discount as a percentage of the total list price,
+	// weighting each line's discount by its list price.
+	EffectiveDiscount float64 `json:"effectiveDiscount"`
+	// ByProductType contains the totals for each ProductTypeClassification, such as "HARDWARE",
+	// "SOFTWARE", "SERVICE" or "SUBSCRIPTION".  Lines without a classification are totalled
+	// under an empty key.
+	ByProductType map[string]Totals `json:"byProductType,omitempty"`
+	// OneTime and Recurring split the totals by whether each line is charged once or on a
+	// recurring basis, based on its ChargeType and BillingModel.
+	OneTime   Totals `json:"oneTime"`
+	Recurring Totals `json:"recurring"`
+	// UnpricedLines lists the line numbers of lines that have a list price but no net amount,
+	// and aren't fully discounted.  They are excluded from the totals, since counting them would
+	// treat them as fully discounted, but are still included in Change.
+	UnpricedLines []string `json:"unpricedLines,omitempty"`
+	// Change totals the subscription change amounts of the lines that have them, and is nil if
+	// the quote doesn't change an existing subscription.
+	Change *SubscriptionChange `json:"change,omitempty"`
+}
+
+// SubscriptionChange contains the billing and contract amounts before and after a change to an
+// existing subscription, such as a renewal or co-term, and the net change between them.
+type SubscriptionChange struct {
+	CurrentBilling    float64 `json:"currentBillingAmount"`
+	NewBilling        float64 `json:"newBillingAmount"`
+	BillingNetChange  float64 `json:"billingAmountNetChange"`
+	CurrentContract   float64 `json:"currentContractAmount"`
+	NewContract       float64 `json:"newContractAmount"`
+	ContractNetChange float64 `json:"contractAmountNetChange"`
+}
+
+// add includes the change amounts of the line.  Where CCW doesn't give the net change but does
+// give both the current and new amounts, the difference between them is used.
+func (c *SubscriptionChange) add(item *AcquireQuoteResponseItem) {
+	c.CurrentBilling = roundAmount(c.CurrentBilling + valueOf(item.CurrentBillingAmount))
+	c.NewBilling = roundAmount(c.NewBilling + valueOf(item.NewBillingAmount))
+	c.BillingNetChange = roundAmount(c.BillingNetChange + netChange(item.BillingAmountNetChange, item.CurrentBillingAmount, item.NewBillingAmount))
+	c.CurrentContract = roundAmount(c.CurrentContract + valueOf(item.CurrentContractAmount))
+	c.NewContract = roundAmount(c.NewContract + valueOf(item.NewContractAmount))
+	c.ContractNetChange = roundAmount(c.ContractNetChange + netChange(item.ContractAmountNetChange, item.CurrentContractAmount, item.NewContractAmount))
+}
+
+// Totals contains the list, net and discount amounts for a group of quote lines.
+type Totals struct {
+	List     float64 `json:"totalList"`
+	Net      float64 `json:"totalNet"`
+	Discount float64 `json:"totalDiscount"`
+}
+
+func (t *Totals) add(list, net float64) {
+	t.List = roundAmount(t.List + list)
+	t.Net = roundAmount(t.Net + net)
+	t.Discount = roundAmount(t.List - t.Net)
+}
+
+// summarizeLineItems calculates the summary for the given quote lines.  Every line is included,
+// since CCW prices the lines of a bundle individually rather than including them in the price of
+// the parent line.
+func summarizeLineItems(items []AcquireQuoteResponseItem) QuoteSummary {
+	var s QuoteSummary
+	for _, item := range items {
+		if s.Currency == "" {
+			s.Currency = item.ImportCurrency
+		}
+		list := item.ExtendedAmount
+		if list == 0 {
+			list = item.UnitPrice * float64(item.Quantity)
+		}
+		if item.hasSubscriptionChange() {
+			if s.Change == nil {
+				s.Change = &SubscriptionChange{}
+			}
+			s.Change.add(&item)
+		}
+		net := item.TotalAmount
+		if net == 0 && list != 0 && item.EffectiveDiscount < 100 && item.TotalDiscount < 100 {
+			s.UnpricedLines = append(s.UnpricedLines, item.LineNumber)
+			continue
+		}
+		s.Totals.add(list, net)
+		if s.ByProductType == nil {
+			s.ByProductType = make(map[string]Totals)
+		}
+		byType := s.ByProductType[item.ProductTypeClassification]
+		byType.add(list, net)
+		s.ByProductType[item.ProductTypeClassification] = byType
+		if item.isRecurring() {
+			s.Recurring.add(list, net)
+		} else {
+			s.OneTime.add(list, net)
+		}
+	}
+	if s.List != 0 {
+		s.EffectiveDiscount = roundAmount(s.Discount / s.List * 100)
+	}
+	return s
+}
+
+// isRecurring reports whether the line is charged on a recurring basis.  The ChargeType is used
+// if present, otherwise lines with a BillingModel other than prepaid, such as "Monthly" or
+// "Annual Billing", are considered recurring.
+func (item *AcquireQuoteResponseItem) isRecurring() bool {
+	if item.ChargeType != nil {
+		chargeType := strings.ToLower(*item.ChargeType)
+		switch {
+		case strings.Contains(chargeType, "non") || strings.Contains(chargeType, "one"):
+			return false
+		case strings.Contains(chargeType, "recurring"):
+			return true
+		}
+	}
+	if item.BillingModel != nil {
+		billingModel := strings.ToLower(*item.BillingModel)
+		return !strings.Contains(billingModel, "prepaid") && !strings.Contains(billingModel, "one")
+	}
+	return false
+}
+
+// hasSubscriptionChange reports whether the line changes an existing subscription, which is the
+// case if any of the subscription change amounts or the old quantity are non-zero.  New quotes
+// may include these fields set to zero.
+func (item *AcquireQuoteResponseItem) hasSubscriptionChange() bool {
+	for _, v := range []*float64{
+		item.CurrentBillingAmount, item.NewBillingAmount, item.BillingAmountNetChange,
+		item.CurrentContractAmount, item.NewContractAmount, item.ContractAmountNetChange,
+	} {
+		if valueOf(v) != 0 {
+			return true
+		}
+	}
+	return item.OldQuantity != nil && *item.OldQuantity != 0
+}
+
+func netChange(change, current, updated *float64) float64 {
+	if change == nil && current != nil && updated != nil {
+		return *updated - *current
+	}
+	return valueOf(change)
+}
+
+func valueOf(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// roundAmount rounds the amount to two decimal places, avoiding floating point artefacts when
+// summing prices.
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
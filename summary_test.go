@@ -0,0 +1,122 @@
+package ccw
+
+import "testing"
+
+func Test_SummarizeLineItems(t *testing.T) {
+	items := []AcquireQuoteResponseItem{
+		{ProductTypeClassification: "HARDWARE", ImportCurrency: "USD", UnitPrice: 4900, Quantity: 2, ExtendedAmount: 9800, TotalAmount: 5880},
+		{ProductTypeClassification: "SERVICE", ImportCurrency: "USD", UnitPrice: 612, Quantity: 2, ExtendedAmount: 1224, TotalAmount: 1101.6, BillingModel: String("Prepaid Term")},
+		{ProductTypeClassification: "SUBSCRIPTION", ImportCurrency: "USD", UnitPrice: 10.1, Quantity: 10, TotalAmount: 80.8, ChargeType: String("Recurring"), BillingModel: String("Monthly")},
+		{ProductTypeClassification: "SUBSCRIPTION", ImportCurrency: "USD", UnitPrice: 0.2, Quantity: 1, TotalAmount: 0.2, BillingModel: String("Annual Billing")},
+	}
+	s := summarizeLineItems(items)
+
+	tests := []struct {
+		name string
+		got  Totals
+		want Totals
+	}{
+		{name: "total", got: s.Totals, want: Totals{List: 11125.2, Net: 7062.6, Discount: 4062.6}},
+		{name: "hardware", got: s.ByProductType["HARDWARE"], want: Totals{List: 9800, Net: 5880, Discount: 3920}},
+		{name: "service", got: s.ByProductType["SERVICE"], want: Totals{List: 1224, Net: 1101.6, Discount: 122.4}},
+		{name: "subscription", got: s.ByProductType["SUBSCRIPTION"], want: Totals{List: 101.2, Net: 81, Discount: 20.2}},
+		{name: "one time", got: s.OneTime, want: Totals{List: 11024, Net: 6981.6, Discount: 4042.4}},
+		{name: "recurring", got: s.Recurring, want: Totals{List: 101.2, Net: 81, Discount: 20.2}},
+	}
+	for _, tc := range tests {
+		if tc.got != tc.want {
+			t.Errorf("%s: expected %+v, got %+v", tc.name, tc.want, tc.got)
+		}
+	}
+	if s.Currency != "USD" || s.EffectiveDiscount != 36.52 {
+		t.Errorf("unexpected currency %q or effective discount %v", s.Currency, s.EffectiveDiscount)
+	}
+
+	if empty := summarizeLineItems(nil); empty.EffectiveDiscount != 0 || empty.ByProductType != nil {
+		t.Errorf("unexpected summary of no lines %+v", empty)
+	}
+}
+
+func Test_SummarizeUnpricedLines(t *testing.T) {
+	items := []AcquireQuoteResponseItem{
+		{LineNumber: "1.0", ProductTypeClassification: "HARDWARE", UnitPrice: 100, Quantity: 1, ExtendedAmount: 100, TotalAmount: 60},
+		// no net amount was returned, so the line mustn't count as fully discounted
+		{LineNumber: "2.0", ProductTypeClassification: "HARDWARE", UnitPrice: 50, Quantity: 2},
+		// a line that really is free is still included
+		{LineNumber: "3.0", ProductTypeClassification: "SERVICE", UnitPrice: 20, Quantity: 1, ExtendedAmount: 20, TotalDiscount: 100},
+		// a line without any amounts has nothing to count or flag
+		{LineNumber: "4.0", ProductTypeClassification: "SERVICE"},
+	}
+	s := summarizeLineItems(items)
+	if want := (Totals{List: 120, Net: 60, Discount: 60}); s.Totals != want {
+		t.Errorf("expected %+v, got %+v", want, s.Totals)
+	}
+	if s.EffectiveDiscount != 50 {
+		t.Errorf("expected an effective discount of 50, got %v", s.EffectiveDiscount)
+	}
+	if len(s.UnpricedLines) != 1 || s.UnpricedLines[0] != "2.0" {
+		t.Errorf("expected line 2.0 to be unpriced, got %v", s.UnpricedLines)
+	}
+}
+
+func Test_IsRecurring(t *testing.T) {
+	tests := []struct {
+		chargeType, billingModel *string
+		want                     bool
+	}{
+		{want: false},
+		{chargeType: String("Recurring"), want: true},
+		{chargeType: String("Non-Recurring"), billingModel: String("Monthly"), want: false},
+		{chargeType: String("One Time"), want: false},
+		{billingModel: String("Prepaid Term"), want: false},
+		{billingModel: String("Quarterly"), want: true},
+	}
+	for i, tc := range tests {
+		item := AcquireQuoteResponseItem{ChargeType: tc.chargeType, BillingModel: tc.billingModel}
+		if got := item.isRecurring(); got != tc.want {
+			t.Errorf("case %d: expected %v, got %v", i, tc.want, got)
+		}
+	}
+}
+
+func Test_SummarizeSubscriptionChange(t *testing.T) {
+	items := []AcquireQuoteResponseItem{
+		{CurrentBillingAmount: Float64(1000), NewBillingAmount: Float64(1101.6), BillingAmountNetChange: Float64(101.6)},
+		{CurrentBillingAmount: Float64(200), NewBillingAmount: Float64(150), CurrentContractAmount: Float64(600), NewContractAmount: Float64(450), ContractAmountNetChange: Float64(-150)},
+		{TotalAmount: 50},
+	}
+	want := SubscriptionChange{CurrentBilling: 1200, NewBilling: 1251.6, BillingNetChange: 51.6, CurrentContract: 600, NewContract: 450, ContractNetChange: -150}
+	if got := summarizeLineItems(items).Change; got == nil || *got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := summarizeLineItems(items[2:]).Change; got != nil {
+		t.Errorf("expected no change for a new quote, got %+v", got)
+	}
+
+	zero := []AcquireQuoteResponseItem{{CurrentBillingAmount: Float64(0), BillingAmountNetChange: Float64(0), ContractAmountNetChange: Float64(0), OldQuantity: Int64(0)}}
+	if got := summarizeLineItems(zero).Change; got != nil {
+		t.Errorf("expected no change for a new quote with zero change amounts, got %+v", got)
+	}
+
+	netOnly := []AcquireQuoteResponseItem{{ContractAmountNetChange: Float64(-75.5)}}
+	want = SubscriptionChange{ContractNetChange: -75.5}
+	if got := summarizeLineItems(netOnly).Change; got == nil || *got != want {
+		t.Errorf("expected %+v for a line with only a contract net change, got %+v", want, got)
+	}
+
+	quantityOnly := []AcquireQuoteResponseItem{{OldQuantity: Int64(5)}}
+	if got := summarizeLineItems(quantityOnly).Change; got == nil || *got != (SubscriptionChange{}) {
+		t.Errorf("expected an empty change for a line with only an old quantity, got %+v", got)
+	}
+
+	// a co-term reduction without a net amount is left out of the totals, but not the change
+	unpriced := []AcquireQuoteResponseItem{{LineNumber: "1.0", UnitPrice: 100, Quantity: 1, ExtendedAmount: 100, BillingAmountNetChange: Float64(-100)}}
+	s := summarizeLineItems(unpriced)
+	want = SubscriptionChange{BillingNetChange: -100}
+	if s.Change == nil || *s.Change != want {
+		t.Errorf("expected %+v for an unpriced line, got %+v", want, s.Change)
+	}
+	if len(s.UnpricedLines) != 1 || s.Totals != (Totals{}) {
+		t.Errorf("expected the line to be unpriced, got %v and %+v", s.UnpricedLines, s.Totals)
+	}
+}
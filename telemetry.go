@@ -0,0 +1,104 @@
+package ccw
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the library to OpenTelemetry.
+const instrumentationName = "github.com/darrenparkinson/ccw"
+
+// telemetry holds the OpenTelemetry tracer and metric instruments used by the client.  Unless
+// providers are given using WithTracerProvider and WithMeterProvider, they do nothing.
+type telemetry struct {
+	tracer         trace.Tracer
+	requests       metric.Int64Counter
+	errors         metric.Int64Counter
+	retries        metric.Int64Counter
+	tokenRefreshes metric.Int64Counter
+	duration       metric.Float64Histogram
+	limiterWait    metric.Float64Histogram
+}
+
+func newTelemetry(tp trace.TracerProvider, mp metric.MeterProvider) (*telemetry, error) {
+	if tp == nil {
+		tp = trace.NewNoopTracerProvider()
+	}
+	if mp == nil {
+		mp = noop.NewMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+	t := &telemetry{tracer: tp.Tracer(instrumentationName)}
+	var err error
+	if t.requests, err = meter.Int64Counter("ccw.requests",
+		metric.WithDescription("Number of HTTP requests made to CCW."),
+	); err != nil {
+		return nil, err
+	}
+	if t.errors, err = meter.Int64Counter("ccw.errors",
+		metric.WithDescription("Number of HTTP requests to CCW that failed."),
+	); err != nil {
+		return nil, err
+	}
+	if t.retries, err = meter.Int64Counter("ccw.retries",
+		metric.WithDescription("Number of HTTP requests to CCW that were retried."),
+	); err != nil {
+		return nil, err
+	}
+	if t.tokenRefreshes, err = meter.Int64Counter("ccw.token.refreshes",
+		metric.WithDescription("Number of tokens requested from the token source."),
+	); err != nil {
+		return nil, err
+	}
+	if t.duration, err = meter.Float64Histogram("ccw.request.duration",
+		metric.WithDescription("Duration of HTTP requests made to CCW."),
+		metric.WithUnit("s"),
+	); err != nil {
+		return nil, err
+	}
+	if t.limiterWait, err = meter.Float64Histogram("ccw.ratelimit.wait",
+		metric.WithDescription("Time spent waiting for the rate limiter before each request."),
+		metric.WithUnit("s"),
+	); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// operationKey is the context key holding the name of the current operation, so that the
+// requests it makes can be attributed to it.
+type operationKey struct{}
+
+func operationFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(operationKey{}).(string)
+	return name
+}
+
+// operationAttributes converts the alternating keys and values used for logging to span attributes.
+func operationAttributes(name string, args []interface{}) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("ccw.operation", name)}
+	for i := 0; i+1 < len(args); i += 2 {
+		attrs = append(attrs, attribute.String("ccw."+fmt.Sprint(args[i]), fmt.Sprint(args[i+1])))
+	}
+	return attrs
+}
+
+// recordRequest records the metrics for a single HTTP request.  status is zero if no response
+// was received.
+func (t *telemetry) recordRequest(ctx context.Context, status int, d time.Duration, failed bool) {
+	opt := metric.WithAttributes(
+		attribute.String("ccw.operation", operationFromContext(ctx)),
+		attribute.Int("http.status_code", status),
+	)
+	t.requests.Add(ctx, 1, opt)
+	t.duration.Record(ctx, d.Seconds(), opt)
+	if failed {
+		t.errors.Add(ctx, 1, opt)
+	}
+}
@@ -0,0 +1,82 @@
+package ccw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_Telemetry(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	c, err := New(
+		WithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"})),
+		WithBaseURL(ServiceQuote, srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2}),
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.QuoteService.AcquireByDealID(context.Background(), "12345"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	names := map[string]int{}
+	for _, s := range spans.Ended() {
+		names[s.Name()]++
+		if s.Name() != "ccw.AcquireQuote" {
+			continue
+		}
+		attrs := attribute.NewSet(s.Attributes()...)
+		if v, _ := attrs.Value("ccw.deal_id"); v.AsString() != "12345" {
+			t.Errorf("expected deal id attribute, got %v", s.Attributes())
+		}
+		if v, _ := attrs.Value("http.status_code"); v.AsInt64() != http.StatusBadRequest {
+			t.Errorf("expected status attribute, got %v", s.Attributes())
+		}
+	}
+	if names["ccw.AcquireQuote"] != 1 || names["ccw.request"] != 2 || names["ccw.token"] != 1 {
+		t.Errorf("unexpected spans %v", names)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	sums := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range sum.DataPoints {
+					sums[m.Name] += dp.Value
+				}
+			}
+		}
+	}
+	want := map[string]int64{"ccw.requests": 2, "ccw.errors": 2, "ccw.retries": 1, "ccw.token.refreshes": 1}
+	for name, v := range want {
+		if sums[name] != v {
+			t.Errorf("%s: expected %d, got %d", name, v, sums[name])
+		}
+	}
+}
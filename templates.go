@@ -0,0 +1,158 @@
+package ccw
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+var (
+	templatesMu sync.RWMutex
+	// parsedTemplates holds each template once it has been parsed, keyed by file name, e.g.
+	// "AcquireQuote_Request.xml".  Templates are parsed from the embedded templates on first use,
+	// unless they have been replaced using RegisterTemplate.
+	parsedTemplates = map[string]*template.Template{}
+)
+
+// DefaultTemplates returns the embedded templates used to build requests, so that they can be
+// used as the starting point for a replacement registered with RegisterTemplate.
+func DefaultTemplates() fs.FS {
+	sub, _ := fs.Sub(templates, "templates")
+	return sub
+}
+
+// RegisterTemplate replaces the named request template, such as "AcquireQuote_Request.xml",
+// with the given text, or adds a new template if there is no template with that name.  The
+// output of every action is XML escaped.  It is safe to call concurrently with requests, which
+// use the new template from then on.
+func RegisterTemplate(name, text string) error {
+	t, err := newTemplate(name).Parse(text)
+	if err != nil {
+		return err
+	}
+	escapeTemplate(t)
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	parsedTemplates[name] = t
+	return nil
+}
+
+// RegisterTemplateFS registers each file in fsys matching the patterns, as RegisterTemplate
+// does, using the base name of the file as the template name.
+func RegisterTemplateFS(fsys fs.FS, patterns ...string) error {
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("ccw: pattern matches no files: %q", pattern)
+		}
+		for _, name := range names {
+			b, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			if err := RegisterTemplate(path.Base(name), string(b)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookupTemplate returns the named template, parsing it from the embedded templates the first
+// time it is used.  The returned template is shared and must not be modified, but may be
+// executed concurrently.
+func lookupTemplate(name string) (*template.Template, error) {
+	templatesMu.RLock()
+	t, ok := parsedTemplates[name]
+	templatesMu.RUnlock()
+	if ok {
+		return t, nil
+	}
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	if t, ok := parsedTemplates[name]; ok {
+		return t, nil
+	}
+	t, err := parseTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	parsedTemplates[name] = t
+	return t, nil
+}
+
+// parseTemplate loads the named template from the embedded templates and ensures that the
+// output of every action is XML escaped.  text/template performs no escaping of its own, so
+// without this any data containing characters such as < or & would be able to alter the
+// structure of the request.
+func parseTemplate(name string) (*template.Template, error) {
+	t, err := newTemplate(name).ParseFS(templates, "templates/"+name)
+	if err != nil {
+		return nil, err
+	}
+	escapeTemplate(t)
+	return t, nil
+}
+
+func newTemplate(name string) *template.Template {
+	return template.New(name).Funcs(template.FuncMap{"xmlescape": xmlEscape})
+}
+
+// escapeTemplate adds XML escaping to every template associated with t.
+func escapeTemplate(t *template.Template) {
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			escapeNode(tmpl.Tree, tmpl.Tree.Root)
+		}
+	}
+}
+
+// escapeNode walks the parse tree, adding xmlescape to the end of every pipeline that produces output.
+func escapeNode(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeNode(tree, child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		cmds := n.Pipe.Cmds
+		if len(cmds) > 0 {
+			last := cmds[len(cmds)-1]
+			if id, ok := last.Args[0].(*parse.IdentifierNode); ok && id.Ident == "xmlescape" {
+				return
+			}
+		}
+		ident := parse.NewIdentifier("xmlescape").SetTree(tree).SetPos(n.Pos)
+		n.Pipe.Cmds = append(cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{ident}})
+	case *parse.IfNode:
+		escapeNode(tree, n.List)
+		escapeNode(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeNode(tree, n.List)
+		escapeNode(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeNode(tree, n.List)
+		escapeNode(tree, n.ElseList)
+	}
+}
+
+// xmlEscape returns the XML escaped textual representation of v.
+func xmlEscape(v interface{}) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(fmt.Sprint(v)))
+	return b.String()
+}
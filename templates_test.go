@@ -0,0 +1,95 @@
+package ccw
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_ParseTemplateEscapesData(t *testing.T) {
+	tmpl, err := lookupTemplate("ProcessEstimate_Request.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := processEstimateTemplateData{
+		ActionCode: `Add"`,
+		Name:       "<Injected>&</Injected>",
+		Lines: []processEstimateTemplateLine{
+			{LineNumber: "1.0", PartNumber: "A</ID><ID>B", Quantity: 1},
+		},
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if strings.Contains(out, "<Injected>") || strings.Contains(out, "A</ID><ID>B") {
+		t.Fatalf("template data was not escaped:\n%s", out)
+	}
+	if !strings.Contains(out, "&lt;Injected&gt;&amp;&lt;/Injected&gt;") {
+		t.Errorf("expected escaped name in output:\n%s", out)
+	}
+	d := xml.NewDecoder(&b)
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("rendered template is not well formed: %v", err)
+		}
+	}
+}
+
+func Test_TemplateRegistry(t *testing.T) {
+	t1, err := lookupTemplate("AcquireQuote_Request.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := lookupTemplate("AcquireQuote_Request.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t1 != t2 {
+		t.Error("expected the parsed template to be cached")
+	}
+	if _, err := lookupTemplate("Missing_Request.xml"); err == nil {
+		t.Error("expected an error for a missing template")
+	}
+	if err := RegisterTemplate("Broken_Request.xml", "{{.Unclosed"); err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.Write([]byte(`<Envelope/>`))
+	}))
+	defer srv.Close()
+	c, err := New(WithTokenSource(StaticTokenSource(&Token{AccessToken: "abc"})), WithBaseURL(ServiceQuote, srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// override the template, then restore the default
+	if err := RegisterTemplate("AcquireQuote_Request.xml", `<Deal>{{.DealID}}</Deal>`); err != nil {
+		t.Fatal(err)
+	}
+	c.QuoteService.AcquireByDealID(context.Background(), "12345")
+	if body != "<Deal>12345</Deal>" {
+		t.Errorf("expected the registered template to be used, got %q", body)
+	}
+	if err := RegisterTemplateFS(DefaultTemplates(), "AcquireQuote_Request.xml"); err != nil {
+		t.Fatal(err)
+	}
+	c.QuoteService.AcquireByDealID(context.Background(), "12345")
+	if !strings.Contains(body, `<ns1:Expression expressionLanguage="DealId">12345</ns1:Expression>`) {
+		t.Errorf("expected the default template to be restored, got %q", body)
+	}
+}
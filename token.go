@@ -0,0 +1,137 @@
+package ccw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTokenURL is the Cisco SSO endpoint used to retrieve tokens for the CCW APIs.
+const DefaultTokenURL = "https://cloudsso.cisco.com/as/token.oauth2"
+
+// Token is a bearer token used to authenticate requests to the CCW APIs.
+type Token struct {
+	AccessToken string
+	TokenType   string
+	// Expiry is the time at which the token expires.  A zero value means the token does not expire.
+	Expiry time.Time
+}
+
+// TokenSource is anything that can return a token.  It follows the same semantics as the
+// golang.org/x/oauth2 TokenSource, so an oauth2.TokenSource can easily be adapted for use
+// with the client.  The client caches tokens itself and will only call Token when it doesn't
+// have a valid token, so implementations don't need to.
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// tokenInvalidator is implemented by token sources that cache tokens, allowing the client to
+// discard a cached token that has been rejected.
+type tokenInvalidator interface {
+	Invalidate()
+}
+
+// PasswordTokenSource retrieves tokens using the OAuth2 resource owner password grant.  This is
+// the method traditionally used to access the CCW APIs.
+type PasswordTokenSource struct {
+	// TokenURL is the token endpoint, defaulting to DefaultTokenURL.
+	TokenURL     string
+	Username     string
+	Password     string
+	ClientID     string
+	ClientSecret string
+	// HTTPClient is the client used to request tokens, defaulting to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Token retrieves a new token using the password grant.
+func (ts *PasswordTokenSource) Token() (*Token, error) {
+	return retrieveToken(ts.HTTPClient, ts.TokenURL, url.Values{
+		"grant_type":    {"password"},
+		"username":      {ts.Username},
+		"password":      {ts.Password},
+		"client_id":     {ts.ClientID},
+		"client_secret": {ts.ClientSecret},
+	})
+}
+
+// ClientCredentialsTokenSource retrieves tokens using the OAuth2 client credentials grant.
+type ClientCredentialsTokenSource struct {
+	// TokenURL is the token endpoint, defaulting to DefaultTokenURL.
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// HTTPClient is the client used to request tokens, defaulting to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Token retrieves a new token using the client credentials grant.
+func (ts *ClientCredentialsTokenSource) Token() (*Token, error) {
+	return retrieveToken(ts.HTTPClient, ts.TokenURL, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {ts.ClientID},
+		"client_secret": {ts.ClientSecret},
+	})
+}
+
+type staticTokenSource struct {
+	t *Token
+}
+
+// StaticTokenSource returns a TokenSource that always returns the same token.  This is useful
+// when tokens are provided by some other means, such as a secret broker.
+func StaticTokenSource(t *Token) TokenSource {
+	return staticTokenSource{t}
+}
+
+func (s staticTokenSource) Token() (*Token, error) {
+	if s.t == nil || s.t.AccessToken == "" {
+		return nil, errors.New("ccw: static token source has no access token")
+	}
+	return s.t, nil
+}
+
+// retrieveToken posts the given form values to the token endpoint and decodes the resulting token.
+func retrieveToken(client *http.Client, tokenURL string, v url.Values) (*Token, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if tokenURL == "" {
+		tokenURL = DefaultTokenURL
+	}
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		var e tokenError
+		err = json.NewDecoder(res.Body).Decode(&e)
+		if err != nil {
+			return nil, fmt.Errorf("ccw: token request failed with status %d", res.StatusCode)
+		}
+		return nil, errors.New(e.Description)
+	}
+	var t ccwToken
+	err = json.NewDecoder(res.Body).Decode(&t)
+	if err != nil {
+		return nil, err
+	}
+	token := &Token{
+		AccessToken: t.AccessToken,
+		TokenType:   t.TokenType,
+	}
+	if t.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,120 @@
+package ccw
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_TokenSources(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostForm.Get("grant_type") {
+		case "password":
+			if r.PostForm.Get("username") != "user" || r.PostForm.Get("password") != "p&ss=word" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_grant","error_description":"bad credentials"}`))
+				return
+			}
+		case "client_credentials":
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"unsupported_grant_type","error_description":"unsupported grant type"}`))
+			return
+		}
+		if r.PostForm.Get("client_id") != "id" || r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"bad client"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"abc","token_type":"Bearer","expires_in":3599}`))
+	}))
+	defer idp.Close()
+
+	tests := []struct {
+		name    string
+		ts      TokenSource
+		wantErr bool
+	}{
+		{name: "password", ts: &PasswordTokenSource{TokenURL: idp.URL, Username: "user", Password: "p&ss=word", ClientID: "id", ClientSecret: "secret"}},
+		{name: "password invalid", ts: &PasswordTokenSource{TokenURL: idp.URL, Username: "user", Password: "wrong", ClientID: "id", ClientSecret: "secret"}, wantErr: true},
+		{name: "client credentials", ts: &ClientCredentialsTokenSource{TokenURL: idp.URL, ClientID: "id", ClientSecret: "secret"}},
+	}
+
+	for _, tc := range tests {
+		tok, err := tc.ts.Token()
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if tok.AccessToken != "abc" || tok.TokenType != "Bearer" {
+			t.Errorf("%s: unexpected token %+v", tc.name, tok)
+		}
+		if tok.Expiry.Before(time.Now().Add(59 * time.Minute)) {
+			t.Errorf("%s: unexpected expiry %v", tc.name, tok.Expiry)
+		}
+	}
+
+	static := StaticTokenSource(&Token{AccessToken: "static"})
+	tok, err := static.Token()
+	if err != nil || tok.AccessToken != "static" {
+		t.Errorf("static: unexpected token %+v, %v", tok, err)
+	}
+}
+
+type sequenceTokenSource struct {
+	tokens []string
+	calls  int
+}
+
+func (ts *sequenceTokenSource) Token() (*Token, error) {
+	t := ts.tokens[ts.calls]
+	ts.calls++
+	return &Token{AccessToken: t, Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+func Test_MakeXMLRequestRefreshesTokenOnUnauthorized(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`<Envelope><Body>ok</Body></Envelope>`))
+	}))
+	defer srv.Close()
+
+	ts := &sequenceTokenSource{tokens: []string{"revoked", "new", "newer"}}
+	c, err := NewClientWithTokenSource(ts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Body string `xml:"Body"`
+	}
+	if _, err := c.makeXMLRequest(context.Background(), nil, srv.URL, []byte("<x/>"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if ts.calls != 2 || v.Body != "ok" {
+		t.Errorf("expected 2 tokens to be retrieved and a decoded body, got %d tokens and %q", ts.calls, v.Body)
+	}
+
+	// a token that is rejected after refreshing surfaces ErrUnauthorized
+	ts.tokens = []string{"revoked", "revoked"}
+	ts.calls = 0
+	c.token = nil
+	_, err = c.makeXMLRequest(context.Background(), nil, srv.URL, []byte("<x/>"), &v)
+	if !errors.Is(err, ErrUnauthorized) || ts.calls != 2 {
+		t.Errorf("expected ErrUnauthorized after 2 tokens, got %v after %d tokens", err, ts.calls)
+	}
+}
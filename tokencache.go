@@ -0,0 +1,117 @@
+package ccw
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is how long before expiry a token is considered due for refresh.
+const tokenExpiryMargin = 5 * time.Minute
+
+// FileCacheTokenSource wraps another TokenSource and caches its tokens on disk, so that a token
+// can be shared across multiple invocations of a program instead of requesting a new one each
+// time.  A new token is only requested from the underlying source when the cached token is
+// close to expiry.
+type FileCacheTokenSource struct {
+	source TokenSource
+	path   string
+	mu     sync.Mutex
+}
+
+// NewFileCacheTokenSource returns a FileCacheTokenSource that caches tokens from ts in dir.
+// Tokens are keyed by username and client ID so that different credentials never share a
+// token.  If dir is empty, a "ccw" directory within os.UserCacheDir is used.
+func NewFileCacheTokenSource(ts TokenSource, dir, username, clientID string) (*FileCacheTokenSource, error) {
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(cacheDir, "ccw")
+	}
+	sum := sha256.Sum256([]byte(username + "\x00" + clientID))
+	return &FileCacheTokenSource{
+		source: ts,
+		path:   filepath.Join(dir, "token-"+hex.EncodeToString(sum[:8])+".json"),
+	}, nil
+}
+
+// Token returns the cached token if it is still valid, otherwise it retrieves a new token from
+// the underlying source and caches it.
+func (ts *FileCacheTokenSource) Token() (*Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if t, err := ts.read(); err == nil && t.ExpiresAt.After(time.Now().Add(tokenExpiryMargin)) {
+		return &Token{AccessToken: t.AccessToken, TokenType: t.TokenType, Expiry: t.ExpiresAt}, nil
+	}
+	t, err := ts.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	// tokens without an expiry can't safely be reused by another process, so aren't cached
+	if !t.Expiry.IsZero() {
+		ts.write(&ccwToken{
+			AccessToken: t.AccessToken,
+			TokenType:   t.TokenType,
+			ExpiresIn:   int(time.Until(t.Expiry).Seconds()),
+			ExpiresAt:   t.Expiry,
+		})
+	}
+	return t, nil
+}
+
+// Invalidate removes the cached token, so that the next call to Token retrieves a new token from
+// the underlying source.  The client calls this when a token is rejected by CCW.
+func (ts *FileCacheTokenSource) Invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	os.Remove(ts.path)
+}
+
+func (ts *FileCacheTokenSource) read() (*ccwToken, error) {
+	b, err := os.ReadFile(ts.path)
+	if err != nil {
+		return nil, err
+	}
+	var t ccwToken
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// write saves the token to disk, readable only by the current user.  The token is written to
+// a temporary file first and renamed so other processes never see a partially written token.
+// Failing to cache the token isn't fatal, so errors are ignored.
+func (ts *FileCacheTokenSource) write(t *ccwToken) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(ts.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(dir, ".token-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return
+	}
+	if err := f.Close(); err != nil {
+		return
+	}
+	os.Rename(f.Name(), ts.path)
+}
@@ -0,0 +1,63 @@
+package ccw
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+type countingTokenSource struct {
+	calls  int
+	expiry time.Duration
+}
+
+func (ts *countingTokenSource) Token() (*Token, error) {
+	ts.calls++
+	return &Token{AccessToken: "abc", TokenType: "Bearer", Expiry: time.Now().Add(ts.expiry)}, nil
+}
+
+func Test_FileCacheTokenSource(t *testing.T) {
+	dir := t.TempDir()
+	src := &countingTokenSource{expiry: time.Hour}
+
+	for i := 0; i < 3; i++ {
+		// a new cache each time simulates separate invocations of the cli
+		ts, err := NewFileCacheTokenSource(src, dir, "user", "id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tok, err := ts.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.AccessToken != "abc" {
+			t.Errorf("unexpected token %+v", tok)
+		}
+		fi, err := os.Stat(ts.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0600 {
+			t.Errorf("expected permissions 0600, got %v", fi.Mode().Perm())
+		}
+	}
+	if src.calls != 1 {
+		t.Errorf("expected 1 call to the token source, got %d", src.calls)
+	}
+
+	// different credentials must not share a token
+	other, _ := NewFileCacheTokenSource(src, dir, "other", "id")
+	other.Token()
+	if src.calls != 2 {
+		t.Errorf("expected 2 calls to the token source, got %d", src.calls)
+	}
+
+	// tokens close to expiry are refreshed
+	expiring := &countingTokenSource{expiry: time.Minute}
+	ts, _ := NewFileCacheTokenSource(expiring, dir, "expiring", "id")
+	ts.Token()
+	ts.Token()
+	if expiring.calls != 2 {
+		t.Errorf("expected 2 calls to the token source, got %d", expiring.calls)
+	}
+}
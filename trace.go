@@ -0,0 +1,178 @@
+package ccw
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// redacted replaces credentials in traces.
+const redacted = "REDACTED"
+
+// Trace captures a single HTTP request made by the client, including token requests, and its
+// response.  Credentials are redacted before a Trace is passed to a TraceFunc.
+type Trace struct {
+	Method         string
+	URL            string
+	RequestHeader  http.Header
+	RequestBody    []byte
+	StatusCode     int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	Start          time.Time
+	Duration       time.Duration
+	// Err is the error returned when the request failed without a response.
+	Err error
+}
+
+// TraceFunc is called with each Trace once its response has been read.  It may be called
+// concurrently.
+type TraceFunc func(*Trace)
+
+// WriteTo writes the trace in a readable form, similar to the HTTP wire format.
+func (t *Trace) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "=== %s %s\n", t.Start.UTC().Format(time.RFC3339Nano), t.Duration)
+	fmt.Fprintf(&b, "%s %s\n", t.Method, t.URL)
+	t.RequestHeader.Write(&b)
+	fmt.Fprintf(&b, "\n%s\n\n", t.RequestBody)
+	if t.Err != nil {
+		fmt.Fprintf(&b, "--- error: %v\n\n", t.Err)
+		return b.WriteTo(w)
+	}
+	fmt.Fprintf(&b, "--- %d %s\n", t.StatusCode, http.StatusText(t.StatusCode))
+	t.ResponseHeader.Write(&b)
+	fmt.Fprintf(&b, "\n%s\n\n", t.ResponseBody)
+	return b.WriteTo(w)
+}
+
+// TraceWriter returns a TraceFunc that writes each trace to w.
+func TraceWriter(w io.Writer) TraceFunc {
+	var mu sync.Mutex
+	return func(t *Trace) {
+		mu.Lock()
+		defer mu.Unlock()
+		t.WriteTo(w)
+	}
+}
+
+// TraceDir returns a TraceFunc that writes each trace to its own file in dir, named after the
+// time of the request, so that individual calls can be attached to support cases.  Failing to
+// write a trace isn't fatal, so errors are ignored.
+func TraceDir(dir string) TraceFunc {
+	var seq int64
+	return func(t *Trace) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return
+		}
+		name := fmt.Sprintf("ccw-%s-%04d.txt", t.Start.UTC().Format("20060102T150405.000"), atomic.AddInt64(&seq, 1))
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		t.WriteTo(f)
+	}
+}
+
+// NewTraceTransport returns an http.RoundTripper that passes each request made through base, or
+// http.DefaultTransport if base is nil, and its response to fn with credentials redacted.  This is
+// used by WithTrace, and may be used to trace requests made by a custom TokenSource.
+func NewTraceTransport(base http.RoundTripper, fn TraceFunc) http.RoundTripper {
+	return &traceTransport{base: base, trace: fn}
+}
+
+// traceTransport is an http.RoundTripper that passes a redacted copy of each request and
+// response to a TraceFunc.
+type traceTransport struct {
+	base  http.RoundTripper
+	trace TraceFunc
+}
+
+func (tt *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := &Trace{
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		RequestHeader: redactHeader(req.Header),
+		Start:         time.Now(),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		t.RequestBody = redactBody(req.Header.Get("Content-Type"), body)
+	}
+	base := tt.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		t.Duration = time.Since(t.Start)
+		t.Err = err
+		tt.trace(t)
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	t.Duration = time.Since(t.Start)
+	if err != nil {
+		t.Err = err
+		tt.trace(t)
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	t.StatusCode = res.StatusCode
+	t.ResponseHeader = redactHeader(res.Header)
+	t.ResponseBody = redactBody(res.Header.Get("Content-Type"), body)
+	tt.trace(t)
+	return res, nil
+}
+
+// sensitiveHeaders are headers whose values are always redacted.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// sensitiveFields are form fields and JSON properties whose values are redacted, which covers
+// the password and secrets sent to the token endpoint and the tokens it returns.
+var sensitiveFields = []string{"password", "client_secret", "access_token", "refresh_token", "id_token"}
+
+var sensitiveJSON = regexp.MustCompile(`("(?:` + strings.Join(sensitiveFields, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range sensitiveHeaders {
+		if _, ok := h[k]; ok {
+			h.Set(k, redacted)
+		}
+	}
+	return h
+}
+
+func redactBody(contentType string, body []byte) []byte {
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		v, err := url.ParseQuery(string(body))
+		if err != nil {
+			return []byte(redacted)
+		}
+		for _, k := range sensitiveFields {
+			if _, ok := v[k]; ok {
+				v.Set(k, redacted)
+			}
+		}
+		return []byte(v.Encode())
+	}
+	return sensitiveJSON.ReplaceAll(body, []byte(`${1}"`+redacted+`"`))
+}
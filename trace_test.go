@@ -0,0 +1,67 @@
+package ccw
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_Trace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("password") != "p&ss" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"secret-token","token_type":"Bearer","expires_in":3599}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`<Envelope><Body>ok</Body></Envelope>`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	dir := t.TempDir()
+	writer, files := TraceWriter(&buf), TraceDir(dir)
+	httpClient := &http.Client{}
+	c, err := New(
+		WithCredentials("user", "p&ss", "id", "client-secret"),
+		WithTokenURL(srv.URL+"/token"),
+		WithHTTPClient(httpClient),
+		WithTrace(func(t *Trace) { writer(t); files(t) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if httpClient.Transport != nil {
+		t.Error("expected the given http client to be left unchanged")
+	}
+	if _, err := c.makeXMLRequest(context.Background(), nil, srv.URL+"/quoting", []byte("<Request>12345</Request>"), &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"POST " + srv.URL + "/token", "<Request>12345</Request>", "<Body>ok</Body>", "Authorization: REDACTED", "password=REDACTED", "client_secret=REDACTED", `"access_token":"REDACTED"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected trace to contain %q, got:\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{"p%26ss", "client-secret", "secret-token"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %q to be redacted, got:\n%s", secret, out)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("expected 2 trace files, got %d: %v", len(entries), err)
+	}
+}